@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+)
+
+// number of terms listed per field by the stats command
+const topTermsCount = 10
+
+// replaces the html highlighting of fragments for the terminal
+var fragmentReplacer = strings.NewReplacer("<mark>", "*", "</mark>", "*", "\n", " ")
+
+// runs the query against the index and prints the hits
+func queryCommand(w io.Writer, indexPath string, q string, size int) {
+	index := openIndex(indexPath)
+	defer index.Close()
+
+	request := bleve.NewSearchRequestOptions(bleve.NewQueryStringQuery(q), size, 0, false)
+	request.Fields = []string{"title", "date"}
+	request.Highlight = bleve.NewHighlightWithStyle("html")
+	request.Highlight.AddField("content")
+	result, err := index.Search(request)
+	exitOnError(err)
+
+	fmt.Fprintf(w, "%d hits for %q (%v)\n", result.Total, q, result.Took)
+	for i, hit := range result.Hits {
+		fmt.Fprintf(w, "\n%2d. %v\n    %s  (score: %.3f)\n", i+1, hit.Fields["title"], hit.ID, hit.Score)
+		for _, fragment := range hit.Fragments["content"] {
+			fmt.Fprintf(w, "    %s\n", fragmentReplacer.Replace(fragment))
+		}
+	}
+}
+
+// prints the document count, the fields and the top terms of the fields
+func statsCommand(w io.Writer, indexPath string, fields []string) {
+	index := openIndex(indexPath)
+	defer index.Close()
+
+	count, err := index.DocCount()
+	exitOnError(err)
+	names, err := index.Fields()
+	exitOnError(err)
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Index:     %s\n", indexPath)
+	fmt.Fprintf(w, "Documents: %d\n", count)
+	fmt.Fprintf(w, "Fields:    %s\n", strings.Join(names, ", "))
+
+	for _, field := range fields {
+		fmt.Fprintf(w, "\nTop terms (%s):\n", field)
+		for _, entry := range topTerms(index, field, topTermsCount) {
+			fmt.Fprintf(w, "  %-20s %d\n", entry.Term, entry.Count)
+		}
+	}
+}
+
+// opens the index read-only, so that it can be queried while it is served
+func openIndex(indexPath string) bleve.Index {
+	index, err := bleve.OpenUsing(indexPath, map[string]interface{}{"read_only": true})
+	exitOnError(err)
+	return index
+}
+
+// returns the terms of the field that occur in the most documents
+func topTerms(index bleve.Index, field string, n int) (terms []termCount) {
+	dict, err := index.FieldDict(field)
+	exitOnError(err)
+	defer dict.Close()
+
+	for {
+		entry, err := dict.Next()
+		exitOnError(err)
+		if entry == nil {
+			break
+		}
+		terms = append(terms, termCount{entry.Term, entry.Count})
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return terms[i].Count > terms[j].Count
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// termCount is a term of the index with the number of documents it occurs in
+type termCount struct {
+	Term  string `json:"term"`
+	Count uint64 `json:"count"`
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tischda/hugo-search/hugosearch"
+)
+
+const testHugoPath = "test"
+
+// builds the index of the test site in a temporary directory of the test and returns its path
+func buildTestIndex(t *testing.T) string {
+	indexPath := filepath.Join(t.TempDir(), "search.bleve")
+	indexer := hugosearch.NewIndexer(hugosearch.Options{SitePath: testHugoPath, IndexPath: indexPath})
+	if err := indexer.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return indexPath
+}
+
+// checks that the query command prints the matching pages
+func TestQueryCommand(t *testing.T) {
+	indexPath := buildTestIndex(t)
+	var out bytes.Buffer
+	queryCommand(&out, commandIndexPath(indexPath, ""), "lorem", 10)
+
+	expected := "Title-page-1"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Expected %q in output:\n%s", expected, out.String())
+	}
+}
+
+// checks that the stats command prints the document count and the top terms
+func TestStatsCommand(t *testing.T) {
+	indexPath := buildTestIndex(t)
+	var out bytes.Buffer
+	statsCommand(&out, commandIndexPath(indexPath, ""), []string{"content"})
+
+	for _, expected := range []string{"Documents: ", "Top terms (content):", "lorem"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/tischda/hugo-search/hugosearch"
+)
+
+// checks if the command reads the site config: the commands that build the index do, serve only
+// when the path of the site is set, the commands that only read the index do not
+func readsSiteConfig(command string, set map[string]bool) bool {
+	switch command {
+	case "", "index", "export":
+		return true
+	case "serve":
+		return set["hugoPath"]
+	}
+	return false
+}
+
+// applies the settings of the site config to the options that were not set on the command line
+func applySearchConfig(c hugosearch.Config, hugoPath string, bindAddr *string, indexPath *string, rulesPath *string) {
+	set := setFlags()
+	if c.Addr != "" && !set["addr"] {
+		*bindAddr = c.Addr
+	}
+	if c.IndexPath != "" && !set["indexPath"] {
+		*indexPath = c.IndexPath
+		if !filepath.IsAbs(*indexPath) {
+			*indexPath = filepath.Join(hugoPath, *indexPath)
+		}
+	}
+	if c.Rules != "" && !set["rules"] {
+		*rulesPath = c.Rules
+		if !filepath.IsAbs(*rulesPath) {
+			*rulesPath = filepath.Join(hugoPath, *rulesPath)
+		}
+	}
+}
+
+// replaces the CORS settings of the site config by the options set on the command line, lists are comma-separated
+func applyCorsFlags(c *hugosearch.Config, set map[string]bool, origins string, methods string, headers string, credentials bool, maxAge int) error {
+	if set["corsOrigins"] {
+		c.CorsOrigins = splitList(origins)
+	}
+	if set["corsMethods"] {
+		c.CorsMethods = splitList(methods)
+	}
+	if set["corsHeaders"] {
+		c.CorsHeaders = splitList(headers)
+	}
+	if set["corsCredentials"] {
+		c.CorsCredentials = credentials
+	}
+	if set["corsMaxAge"] {
+		c.CorsMaxAge = maxAge
+	}
+	return c.ValidateCors()
+}
+
+// returns the names of the options set on the command line
+func setFlags() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// splits a comma-separated list, ignoring empty values
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tischda/hugo-search/hugosearch"
+)
+
+// checks that the site config sets the options that are not on the command line
+func TestApplySearchConfig(t *testing.T) {
+	bindAddr, indexPath, rulesPath := ":8080", "indexes/search.bleve", ""
+	applySearchConfig(hugosearch.Config{Addr: ":9090", IndexPath: "public/search.bleve", Rules: "rules.yaml"}, testHugoPath, &bindAddr, &indexPath, &rulesPath)
+
+	if bindAddr != ":9090" || indexPath != "test/public/search.bleve" || rulesPath != "test/rules.yaml" {
+		t.Errorf("Expected: :9090 test/public/search.bleve test/rules.yaml, was: %s %s %s", bindAddr, indexPath, rulesPath)
+	}
+}
+
+// checks that the CORS options on the command line replace the site config
+func TestApplyCorsFlags(t *testing.T) {
+	c := hugosearch.Config{CorsOrigins: []string{"https://example.com"}, CorsMaxAge: 600}
+	set := map[string]bool{"corsOrigins": true, "corsMethods": true}
+	if err := applyCorsFlags(&c, set, "https://a.com, https://b.com", "GET,OPTIONS", "X-Ignored", true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.CorsOrigins, []string{"https://a.com", "https://b.com"}) || !reflect.DeepEqual(c.CorsMethods, []string{"GET", "OPTIONS"}) ||
+		c.CorsHeaders != nil || c.CorsCredentials || c.CorsMaxAge != 600 {
+		t.Errorf("Expected origins and methods of the options, was: %+v", c)
+	}
+	set = map[string]bool{"corsOrigins": true, "corsCredentials": true}
+	if err := applyCorsFlags(&c, set, "*", "", "", true, 0); err == nil {
+		t.Errorf("Expected error for credentials with all origins")
+	}
+}
+
+// checks that only the commands building the index need the site
+func TestReadsSiteConfig(t *testing.T) {
+	for _, command := range []string{"", "index", "export"} {
+		if !readsSiteConfig(command, nil) {
+			t.Errorf("Expected %q to read the site config", command)
+		}
+	}
+	for _, command := range []string{"serve", "query", "stats", "rollback"} {
+		if readsSiteConfig(command, nil) {
+			t.Errorf("Expected %q not to read the site config", command)
+		}
+	}
+	if !readsSiteConfig("serve", map[string]bool{"hugoPath": true}) {
+		t.Error("Expected serve to read the config of the site set on the command line")
+	}
+}
@@ -4,9 +4,12 @@ go 1.17
 
 require (
 	github.com/blevesearch/bleve v1.0.14
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/gohugoio/hugo v0.89.4
+	github.com/mitchellh/mapstructure v1.4.2
 	github.com/rs/cors v1.8.0
 	github.com/spf13/afero v1.6.0
+	github.com/spf13/cast v1.4.1
 )
 
 require (
@@ -38,7 +41,6 @@ require (
 	github.com/disintegration/gift v1.2.1 // indirect
 	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/evanw/esbuild v0.14.1 // indirect
-	github.com/fsnotify/fsnotify v1.5.1 // indirect
 	github.com/getkin/kin-openapi v0.83.0 // indirect
 	github.com/ghodss/yaml v1.0.0 // indirect
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
@@ -58,7 +60,6 @@ require (
 	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/miekg/mmark v1.3.6 // indirect
 	github.com/mitchellh/hashstructure v1.1.0 // indirect
-	github.com/mitchellh/mapstructure v1.4.2 // indirect
 	github.com/mschoch/smat v0.2.0 // indirect
 	github.com/muesli/smartcrop v0.3.0 // indirect
 	github.com/niklasfasching/go-org v1.5.0 // indirect
@@ -70,7 +71,6 @@ require (
 	github.com/russross/blackfriday v1.6.0 // indirect
 	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd // indirect
 	github.com/sanity-io/litter v1.5.1 // indirect
-	github.com/spf13/cast v1.4.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/steveyen/gtreap v0.1.0 // indirect
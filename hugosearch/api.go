@@ -0,0 +1,404 @@
+package hugosearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	bleveHttp "github.com/blevesearch/bleve/http"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+
+	// number of documents searched for the pages when the hits of their sections are collapsed
+	collapseWindow = 1000
+)
+
+// fields returned as facets by GET /api/search, with the taxonomies of the site
+// and the string and list params
+var defaultFacets = []string{"section", "author"}
+
+// stored fields of the hits of the responses
+var hitFields = []string{"title", "kind", "heading", "permalink", "summary", "description", "date", "section", "author"}
+
+const (
+	// prefix of the fields holding the terms of the taxonomies
+	taxonomyPrefix = "taxonomies."
+
+	// prefix of the fields holding the front matter params
+	paramPrefix = "params."
+
+	// prefix of the parameters and facets of the search API filtering on the params, so that
+	// params named like the other parameters (e.g. page) are not mistaken for them
+	paramFilterPrefix = "param."
+)
+
+// orders of the hits accepted by the sort parameter
+var sortOrders = map[string][]string{
+	"score": {"-_score"},
+	"date":  {"-date", "-_score"},
+}
+
+// queryHandler answers GET /api/search?q=...&page=...&size=...&section=...&sort=...&lang=...
+// with a searchResponse, taxonomies filter by their plural name (e.g. &tags=go) and
+// string and list params by their name after param. (e.g. &param.product=hugo). When the pages are split at their headings,
+// there is one hit per page unless &collapse=false. The index is the one of the lang parameter, by default the index
+// of a single language site or the combined index of a multilingual site.
+type queryHandler struct {
+	server       *Server
+	defaultIndex string
+}
+
+func newQueryHandler(server *Server, defaultIndex string) *queryHandler {
+	return &queryHandler{server: server, defaultIndex: defaultIndex}
+}
+
+// searchResponse is the response of GET /api/search, it does not depend on bleve's types
+type searchResponse struct {
+	Query  string                 `json:"query"`
+	Total  uint64                 `json:"total"`
+	Page   int                    `json:"page"`
+	Size   int                    `json:"size"`
+	Took   float64                `json:"took_ms"`
+	Hits   []searchHit            `json:"hits"`
+	Facets map[string][]termCount `json:"facets"`
+
+	// banners of the best bets matching the query
+	Banners []Banner `json:"banners,omitempty"`
+}
+
+// searchHit is a page found by GET /api/search, the snippet is html with the matches in <mark>,
+// the summary is the description of the page or the summary written by hugo. Pinned hits are
+// the pages of the best bets.
+type searchHit struct {
+	Title     string  `json:"title"`
+	URL       string  `json:"url"`
+	Kind      string  `json:"kind"`
+	Heading   string  `json:"heading"`
+	Permalink string  `json:"permalink"`
+	Summary   string  `json:"summary"`
+	Snippet   string  `json:"snippet"`
+	Date      string  `json:"date"`
+	Section   string  `json:"section"`
+	Author    string  `json:"author"`
+	Score     float64 `json:"score"`
+	Pinned    bool    `json:"pinned,omitempty"`
+}
+
+func (h *queryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := req.URL.Query()
+	index := h.server.requestedIndex(w, params, h.defaultIndex)
+	if index == nil {
+		return
+	}
+
+	q := params.Get("q")
+	if q == "" {
+		h.server.showError(w, "missing parameter q", http.StatusBadRequest)
+		return
+	}
+	page, err := intParam(params.Get("page"), 1, 1, 1<<20)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("invalid parameter page: %v", err), http.StatusBadRequest)
+		return
+	}
+	size, err := intParam(params.Get("size"), defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("invalid parameter size: %v", err), http.StatusBadRequest)
+		return
+	}
+	sortOrder := "score"
+	if s := params.Get("sort"); s != "" {
+		sortOrder = s
+	}
+	if sortOrders[sortOrder] == nil {
+		h.server.showError(w, fmt.Sprintf("invalid parameter sort: %q", sortOrder), http.StatusBadRequest)
+		return
+	}
+
+	queryString := bleve.NewQueryStringQuery(q)
+	if _, err := queryString.Parse(); err != nil {
+		h.server.showError(w, fmt.Sprintf("error parsing query: %v", err), http.StatusBadRequest)
+		return
+	}
+	conjuncts := []query.Query{queryString}
+	if sections := params["section"]; len(sections) > 0 {
+		conjuncts = append(conjuncts, termsQuery("section", sections))
+	}
+	fields, err := index.Fields()
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error reading fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+	taxonomies := indexedTaxonomies(fields)
+	for _, taxonomy := range taxonomies {
+		if terms := params[taxonomy]; len(terms) > 0 {
+			conjuncts = append(conjuncts, termsQuery(taxonomyPrefix+taxonomy, taxonomyKeys(terms)))
+		}
+	}
+	keywordParams := h.server.opts.Config.keywordParams()
+	for _, param := range keywordParams {
+		if values := params[paramFilterPrefix+param]; len(values) > 0 {
+			conjuncts = append(conjuncts, termsQuery(paramPrefix+param, values))
+		}
+	}
+	// boosted like the conjunctions of search.js sent to _search, so that both rank the hits alike
+	searchQuery := boostQuery(bleve.NewConjunctionQuery(conjuncts...), h.server.boosts)
+
+	facets := make(bleve.FacetsRequest)
+	for _, field := range defaultFacets {
+		facets[field] = bleve.NewFacetRequest(field, 10)
+	}
+	for _, taxonomy := range taxonomies {
+		facets[taxonomy] = bleve.NewFacetRequest(taxonomyPrefix+taxonomy, 10)
+	}
+	for _, param := range keywordParams {
+		facets[paramFilterPrefix+param] = bleve.NewFacetRequest(paramPrefix+param, 10)
+	}
+
+	collapse := params.Get("collapse") != "false" && containsString(fields, "parent")
+	bet := h.server.bestBets.match(q)
+	result, err := bet.search(searchQuery, (page-1)*size, size,
+		func(q query.Query, from int, size int) (*bleve.SearchResult, error) {
+			if collapse {
+				return searchCollapsed(index, q, facets, from, size, sortOrders[sortOrder], h.server.ranking)
+			}
+			request := newHitsRequest(q, size, from, sortOrders[sortOrder])
+			request.Facets = facets
+			return h.server.ranking.search(index, request)
+		},
+		func(ids []string) (*bleve.SearchResult, error) {
+			return index.Search(newHitsRequest(pinnedQuery(ids, searchQuery), len(ids), 0, sortOrders[sortOrder]))
+		})
+	if err == errRescoreWindow {
+		h.server.showError(w, fmt.Sprintf("invalid parameter page: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error executing query: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if result.Total == 0 {
+		h.server.metrics.observeZeroHits("/api/search")
+	}
+	response := newSearchResponse(q, page, size, result)
+	if bet != nil {
+		response.Banners = bet.banners
+	}
+	h.server.writeJSON(w, response)
+}
+
+// returns the request of the hits of a page of results, with the fields of the response
+func newHitsRequest(q query.Query, size int, from int, sortOrder []string) *bleve.SearchRequest {
+	request := bleve.NewSearchRequestOptions(q, size, from, false)
+	request.Fields = hitFields
+	request.SortBy(sortOrder)
+	request.Highlight = bleve.NewHighlightWithStyle("html")
+	request.Highlight.AddField("content")
+	return request
+}
+
+// searches the hits from..from+size with one hit per page of the site, the best of the page and of
+// its sections. The pages are found among the first collapseWindow documents, which limits the
+// total, and are rescored before they are collapsed when sorted by score.
+func searchCollapsed(index bleve.Index, q query.Query, facets bleve.FacetsRequest, from int, size int, sortOrder []string, r ranking) (*bleve.SearchResult, error) {
+	request := bleve.NewSearchRequestOptions(q, collapseWindow, 0, false)
+	request.SortBy(sortOrder)
+	request.Facets = facets
+	rescored, err := r.applies(index)
+	if err != nil {
+		return nil, err
+	}
+	rescored = rescored && sortedByScore(request.Sort)
+	if rescored {
+		request.Fields = []string{weightField, r.field}
+	}
+	matches, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	if rescored {
+		r.rescore(matches.Hits, time.Now())
+	}
+
+	found := make(map[string]bool)
+	var hits search.DocumentMatchCollection
+	for _, hit := range matches.Hits {
+		if link := pageLink(hit.ID); !found[link] {
+			found[link] = true
+			hits = append(hits, hit)
+		}
+	}
+	if from > len(hits) {
+		from = len(hits)
+	}
+	hits = hits[from:]
+	if len(hits) > size {
+		hits = hits[:size]
+	}
+
+	result := &bleve.SearchResult{Hits: search.DocumentMatchCollection{}}
+	if len(hits) > 0 {
+		result, err = searchHits(index, newHitsRequest(q, len(hits), 0, sortOrder), hits)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Total = uint64(len(found))
+	result.Took += matches.Took
+	result.Facets = matches.Facets
+	return result, nil
+}
+
+// returns the index of the lang parameter, or defaultIndex. Reports the error and returns nil if there is none.
+func (s *Server) requestedIndex(w http.ResponseWriter, params url.Values, defaultIndex string) bleve.Index {
+	indexName := defaultIndex
+	if lang := params.Get("lang"); lang != "" {
+		indexName = lang
+	}
+	if indexName == "" {
+		s.showError(w, "missing parameter lang", http.StatusBadRequest)
+		return nil
+	}
+	index := bleveHttp.IndexByName(indexName)
+	if index == nil {
+		s.showError(w, fmt.Sprintf("no such index '%s'", indexName), http.StatusNotFound)
+	}
+	return index
+}
+
+// returns the plural names of the taxonomies having terms in the fields of the index
+func indexedTaxonomies(fields []string) []string {
+	var taxonomies []string
+	for _, field := range fields {
+		if strings.HasPrefix(field, taxonomyPrefix) {
+			taxonomies = append(taxonomies, strings.TrimPrefix(field, taxonomyPrefix))
+		}
+	}
+	sort.Strings(taxonomies)
+	return taxonomies
+}
+
+// checks if the values contain s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the names of the params listed in the settings that are indexed as keywords
+func (c Config) keywordParams() (names []string) {
+	for _, param := range c.indexedParams() {
+		if param.keyword() {
+			names = append(names, param.Name)
+		}
+	}
+	return
+}
+
+// converts the bleve search result to the response of the API
+func newSearchResponse(q string, page int, size int, result *bleve.SearchResult) *searchResponse {
+	response := &searchResponse{
+		Query:  q,
+		Total:  result.Total,
+		Page:   page,
+		Size:   size,
+		Took:   float64(result.Took) / float64(time.Millisecond),
+		Hits:   []searchHit{},
+		Facets: make(map[string][]termCount),
+	}
+	for _, hit := range result.Hits {
+		response.Hits = append(response.Hits, newSearchHit(hit))
+	}
+	for name, facet := range result.Facets {
+		terms := []termCount{}
+		for _, term := range facet.Terms {
+			terms = append(terms, termCount{term.Term, uint64(term.Count)})
+		}
+		response.Facets[name] = terms
+	}
+	return response
+}
+
+// converts a hit having the fields of hitFields to a hit of the response
+func newSearchHit(hit *search.DocumentMatch) searchHit {
+	var snippet string
+	if fragments := hit.Fragments["content"]; len(fragments) > 0 {
+		snippet = fragments[0]
+	}
+	summary := stringField(hit.Fields, "description")
+	if summary == "" {
+		summary = stringField(hit.Fields, "summary")
+	}
+	return searchHit{
+		Title:     stringField(hit.Fields, "title"),
+		URL:       hit.ID,
+		Kind:      stringField(hit.Fields, "kind"),
+		Heading:   stringField(hit.Fields, "heading"),
+		Permalink: stringField(hit.Fields, "permalink"),
+		Summary:   summary,
+		Snippet:   snippet,
+		Date:      stringField(hit.Fields, "date"),
+		Section:   stringField(hit.Fields, "section"),
+		Author:    stringField(hit.Fields, "author"),
+		Score:     hit.Score,
+		Pinned:    hit.Fields[pinnedField] == true,
+	}
+}
+
+// returns a query matching the documents having one of the values in the keyword field
+func termsQuery(field string, values []string) query.Query {
+	var terms []query.Query
+	for _, value := range values {
+		term := bleve.NewTermQuery(value)
+		term.SetField(field)
+		terms = append(terms, term)
+	}
+	return bleve.NewDisjunctionQuery(terms...)
+}
+
+// returns the stored field as a string, fields with several values are joined
+func stringField(fields map[string]interface{}, name string) string {
+	switch value := fields[name].(type) {
+	case string:
+		return value
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return strings.Join(values, ", ")
+	}
+	return ""
+}
+
+// parses an integer parameter between min and max, value is empty when the parameter is missing
+func intParam(value string, defaultValue int, min int, max int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d not between %d and %d", n, min, max)
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sends the GET request to the search API of the test index
+func getSearch(t *testing.T, server *Server, params string) (int, *searchResponse) {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost/api/search?"+params, nil)
+	newQueryHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, nil
+	}
+	var response searchResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	return recorder.Code, &response
+}
+
+func TestQueryHandler(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	_, response := getSearch(t, server, "q=lorem&sort=date")
+	if response.Total != 3 || response.Hits[0].URL != "/parent1/page3/" || response.Hits[0].Title != "Title-page-3" {
+		t.Errorf("Expected 3 hits, newest /parent1/page3/ first, was: %+v", response)
+	}
+	if response.Hits[0].Snippet == "" || response.Hits[0].Author != "Author1Page3, Author2Page3" {
+		t.Errorf("Expected snippet and authors, was: %+v", response.Hits[0])
+	}
+	if len(response.Facets["author"]) == 0 {
+		t.Errorf("Expected author facet, was: %+v", response.Facets)
+	}
+
+	_, response = getSearch(t, server, "q=lorem&tags=tag1")
+	hits := make(map[string]searchHit)
+	for _, hit := range response.Hits {
+		hits[hit.URL] = hit
+	}
+	if hits["/page1/"].Permalink != "http://localhost/page1/" || hits["/page2/"].Summary != "Description of page 2" {
+		t.Errorf("Expected permalink of page 1 and description of page 2, was: %+v", response.Hits)
+	}
+
+	_, response = getSearch(t, server, "q=lorem&section=parent1")
+	if response.Total != 1 || response.Hits[0].Section != "parent1" {
+		t.Errorf("Expected 1 hit in section parent1, was: %+v", response)
+	}
+
+	_, response = getSearch(t, server, "q=lorem&tags=tag2")
+	if response.Total != 1 || response.Hits[0].URL != "/page1/" {
+		t.Errorf("Expected 1 hit tagged tag2, was: %+v", response)
+	}
+	if tags := response.Facets["tags"]; len(tags) != 2 || len(response.Facets["topics"]) != 2 {
+		t.Errorf("Expected facets tags and topics, was: %+v", response.Facets)
+	}
+
+	// terms are matched like hugo matches them, whatever their case
+	_, response = getSearch(t, server, "q=lorem&tags=TAG2")
+	if response.Total != 1 || response.Hits[0].URL != "/page1/" {
+		t.Errorf("Expected 1 hit tagged TAG2, was: %+v", response)
+	}
+
+	_, response = getSearch(t, server, "q=lorem&size=2&page=2")
+	if response.Total != 3 || len(response.Hits) != 1 {
+		t.Errorf("Expected 1 hit on page 2, was: %+v", response)
+	}
+}
+
+// checks that invalid parameters are rejected
+func TestQueryHandlerBadRequest(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	for _, params := range []string{"", "q=lorem&page=0", "q=lorem&size=1000", "q=lorem&sort=title", "q=title:"} {
+		if code, _ := getSearch(t, server, params); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %q, was: %d", http.StatusBadRequest, params, code)
+		}
+	}
+	if code, _ := getSearch(t, server, "q=lorem&lang=xx"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown language, was: %d", http.StatusNotFound, code)
+	}
+}
@@ -0,0 +1,173 @@
+package hugosearch
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/gohugoio/hugo/common/maps"
+	"github.com/gohugoio/hugo/config"
+	"github.com/gohugoio/hugo/hugofs/glob"
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// Config holds the settings of hugo-search found in the site config, in the
+// [params.search] section or in a top-level [search] section
+type Config struct {
+	// http listen address
+	Addr string
+
+	// path of the bleve index, relative to the site
+	IndexPath string
+
+	// sections whose pages are not indexed
+	ExcludeSections []string
+
+	// paths of the content files that are not indexed, relative to the site, e.g. content/internal/**
+	ExcludePaths []string
+
+	// kinds of the pages indexed: page, home, section, taxonomy and term (default page)
+	Kinds []string
+
+	// also index the sections of the pages that start with a h2 or h3 heading
+	SplitHeadings bool
+
+	// front matter params indexed in the params field of the entries, as "name" or "name:type"
+	Params []string
+
+	// boosts of the fields that rank higher when searching all fields
+	Boosts map[string]float64
+
+	// decay of the score of the hits with the age of their page
+	Recency Recency
+
+	// origins allowed to query the search server from a browser, e.g. https://example.com, the
+	// origins of the baseURL of the site when not set, all origins with "*"
+	CorsOrigins []string
+
+	// methods and headers allowed in cross-origin requests, GET, POST, HEAD and the simple headers when empty
+	CorsMethods []string
+	CorsHeaders []string
+
+	// allows cookies and authentication in cross-origin requests, not with all origins
+	CorsCredentials bool
+
+	// seconds that browsers cache the result of a preflight request, not cached when 0
+	CorsMaxAge int
+
+	// file of the best bets rules, relative to the site
+	Rules string
+}
+
+// ReadConfig reads the search settings of the hugo site located at path, a site without config has none
+func ReadConfig(path string) (Config, error) {
+	cfg, _, err := loadSiteConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return newConfig(cfg)
+}
+
+// decodes the search section of the site config, [params.search] takes precedence over [search]
+func newConfig(cfg config.Provider) (c Config, err error) {
+	for _, key := range []string{"params.search", "search"} {
+		if cfg.IsSet(key) {
+			// keys are matched case insensitively, hugo lowers them
+			if err := mapstructure.WeakDecode(cfg.GetStringMap(key), &c); err != nil {
+				return c, fmt.Errorf("invalid search settings: %v", err)
+			}
+			break
+		}
+	}
+	if c.CorsOrigins == nil {
+		c.CorsOrigins = siteOrigins(cfg)
+	}
+	if err := c.ValidateCors(); err != nil {
+		return c, err
+	}
+	for _, pattern := range c.ExcludePaths {
+		if _, err := glob.GetGlob(pattern); err != nil {
+			return c, fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+	for _, kind := range c.Kinds {
+		switch kind {
+		case page.KindPage, page.KindHome, page.KindSection, page.KindTaxonomy, page.KindTerm:
+		default:
+			return c, fmt.Errorf("unknown kind of pages %q, must be one of page, home, section, taxonomy, term", kind)
+		}
+	}
+	for _, spec := range c.Params {
+		if _, err := parseIndexedParam(spec); err != nil {
+			return c, err
+		}
+	}
+	if err := c.Recency.validate(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// checks if the pages of this kind are indexed, by default only the regular pages are
+func (c Config) indexedKind(kind string) bool {
+	if len(c.Kinds) == 0 {
+		return kind == page.KindPage
+	}
+	for _, indexed := range c.Kinds {
+		if indexed == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the pages of the section are excluded from the index
+func (c Config) excludedSection(section string) bool {
+	for _, excluded := range c.ExcludeSections {
+		if excluded == section {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the pattern of the settings matching the path relative to the site, e.g. content/internal/**
+func (c Config) excludedPath(path string) string {
+	for _, pattern := range c.ExcludePaths {
+		g, err := glob.GetGlob(pattern)
+		if err == nil && g.Match(filepath.ToSlash(path)) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// returns the origins of the baseURL of the site and of its languages, e.g. https://example.com
+func siteOrigins(cfg config.Provider) []string {
+	baseURLs := []string{cfg.GetString("baseURL")}
+	for _, language := range cfg.GetStringMap("languages") {
+		baseURLs = append(baseURLs, cast.ToString(maps.ToStringMap(language)["baseurl"]))
+	}
+	var origins []string
+	for _, baseURL := range baseURLs {
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+		origins = appendMissing(origins, u.Scheme+"://"+u.Host)
+	}
+	return origins
+}
+
+// ValidateCors checks the settings of Cross Origin Resource Sharing
+func (c Config) ValidateCors() error {
+	if c.CorsCredentials && containsString(c.CorsOrigins, "*") {
+		return fmt.Errorf("cors credentials cannot be allowed for all origins")
+	}
+	if c.CorsMaxAge < 0 {
+		return fmt.Errorf("invalid cors max age %d, must be a number of seconds", c.CorsMaxAge)
+	}
+	return nil
+}
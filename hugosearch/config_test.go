@@ -0,0 +1,99 @@
+package hugosearch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gohugoio/hugo/config"
+)
+
+// checks that the search section is read from the params of the site config
+func TestNewConfig(t *testing.T) {
+	cfg := config.New()
+	cfg.Set("params", map[string]interface{}{
+		"search": map[string]interface{}{
+			"addr":            ":9090",
+			"indexpath":       "public/search.bleve",
+			"excludesections": []interface{}{"internal"},
+			"params":          []interface{}{"product"},
+			"boosts":          map[string]interface{}{"keywords": 1.5},
+			"corsorigins":     []interface{}{"https://example.com"},
+			"recency":         map[string]interface{}{"halflife": "180d", "min": 0.5},
+		},
+	})
+	actual, err := newConfig(cfg)
+	expected := Config{
+		Addr:            ":9090",
+		IndexPath:       "public/search.bleve",
+		ExcludeSections: []string{"internal"},
+		Params:          []string{"product"},
+		Boosts:          map[string]float64{"keywords": 1.5},
+		CorsOrigins:     []string{"https://example.com"},
+		Recency:         Recency{HalfLife: "180d", Min: 0.5},
+	}
+	if err != nil || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected: %+v, was: %+v (%v)", expected, actual, err)
+	}
+}
+
+// checks that invalid settings are reported
+func TestNewConfigInvalid(t *testing.T) {
+	for _, search := range []map[string]interface{}{
+		{"kinds": []interface{}{"pages"}},
+		{"params": []interface{}{"product:text"}},
+		{"excludepaths": []interface{}{"content/[internal"}},
+		{"recency": map[string]interface{}{"halflife": "soon"}},
+		{"recency": map[string]interface{}{"field": "publishdate"}},
+		{"corsorigins": []interface{}{"*"}, "corscredentials": true},
+		{"corsmaxage": -1},
+	} {
+		cfg := config.New()
+		cfg.Set("search", search)
+		if _, err := newConfig(cfg); err == nil {
+			t.Errorf("Expected error for %v", search)
+		}
+	}
+}
+
+// checks that the origins of the baseURL of the site and its languages are allowed by default
+func TestNewConfigCorsOrigins(t *testing.T) {
+	cfg := config.New()
+	cfg.Set("baseURL", "https://example.com/docs/")
+	cfg.Set("languages", map[string]interface{}{
+		"en": map[string]interface{}{"weight": 1},
+		"fr": map[string]interface{}{"baseurl": "https://example.fr/"},
+	})
+	actual, err := newConfig(cfg)
+	expected := []string{"https://example.com", "https://example.fr"}
+	if err != nil || !reflect.DeepEqual(expected, actual.CorsOrigins) {
+		t.Errorf("Expected: %v, was: %v (%v)", expected, actual.CorsOrigins, err)
+	}
+
+	// an empty list allows no origin
+	cfg.Set("search", map[string]interface{}{"corsorigins": []interface{}{}})
+	if actual, err = newConfig(cfg); err != nil || actual.CorsOrigins == nil || len(actual.CorsOrigins) > 0 {
+		t.Errorf("Expected no origins, was: %v (%v)", actual.CorsOrigins, err)
+	}
+
+	actual, err = ReadConfig(testHugoPath)
+	if err != nil || !reflect.DeepEqual([]string{"http://localhost"}, actual.CorsOrigins) {
+		t.Errorf("Expected origin of the test site, was: %v (%v)", actual.CorsOrigins, err)
+	}
+}
+
+// checks that the boosts of the settings override the default boosts
+func TestFieldBoosts(t *testing.T) {
+	actual := Config{Boosts: map[string]float64{"title": 3, "keywords": 1.5}}.fieldBoosts()
+	expected := map[string]float64{"title": 3, "heading": 1.5, "keywords": 1.5, "summary": 1.2}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected: %v, was: %v", expected, actual)
+	}
+}
+
+// checks that only the sections of the settings are excluded
+func TestExcludedSection(t *testing.T) {
+	c := Config{ExcludeSections: []string{"parent1"}}
+	if !c.excludedSection("parent1") || c.excludedSection("parent2") {
+		t.Errorf("Expected only section parent1 excluded")
+	}
+}
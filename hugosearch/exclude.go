@@ -0,0 +1,66 @@
+package hugosearch
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/spf13/cast"
+)
+
+// returns the pages of the site that are indexed, the others are logged with the reason of their exclusion.
+// The pages excluded by hugo are passed with their reason.
+func (ix *Indexer) indexedPages(pages page.Pages, excluded map[page.Page]string) (page.Pages, error) {
+	siteDir, err := filepath.Abs(ix.opts.SitePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexed page.Pages
+	for _, p := range pages {
+		reason := excluded[p]
+		if reason == "" {
+			reason = ix.exclusionReason(p, siteDir)
+		}
+		if reason != "" {
+			if ix.verbose {
+				ix.log.Printf("Excluded: %s [%s] (%s)", p.Path(), p.Title(), reason)
+			}
+			continue
+		}
+		indexed = append(indexed, p)
+	}
+	return indexed, nil
+}
+
+// returns why the page is not indexed by the settings, or an empty string if it is
+func (ix *Indexer) exclusionReason(p page.Page, siteDir string) string {
+	if !ix.pageHasTitle(p) {
+		return "missing title"
+	}
+	if p.Type() == "search" {
+		return "search page"
+	}
+	if ix.opts.Config.excludedSection(p.Section()) {
+		return fmt.Sprintf("section %s excluded", p.Section())
+	}
+	if value, found := p.Params()["search"]; found {
+		if search, err := cast.ToBoolE(value); err == nil && !search {
+			return "search: false"
+		}
+	}
+	if value, found := p.Params()["noindex"]; found {
+		if noindex, err := cast.ToBoolE(value); err == nil && noindex {
+			return "noindex: true"
+		}
+	}
+	if !p.File().IsZero() {
+		path, err := filepath.Rel(siteDir, p.File().Filename())
+		if err == nil {
+			if pattern := ix.opts.Config.excludedPath(path); pattern != "" {
+				return "path matches " + pattern
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package hugosearch
+
+import (
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testExclusionsHugoPath = "../test/exclusions"
+
+// checks the reasons of the exclusions, by hugo and by the settings
+func TestExclusionReason(t *testing.T) {
+	config, err := ReadConfig(testExclusionsHugoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix := NewIndexer(Options{SitePath: testExclusionsHugoPath, Config: config})
+	pages, excluded, err := ix.readSitePages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	siteDir, _ := filepath.Abs(testExclusionsHugoPath)
+	actual := make(map[string]string)
+	for _, page := range pages {
+		reason := excluded[page]
+		if reason == "" {
+			reason = ix.exclusionReason(page, siteDir)
+		}
+		actual[page.Title()] = reason
+	}
+	expected := map[string]string{
+		"Indexed":  "",
+		"Hidden":   "search: false",
+		"Noindex":  "noindex: true",
+		"Secret":   "path matches content/internal/**",
+		"Unlisted": "not listed",
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected: %v, was: %v", expected, actual)
+	}
+}
+
+// checks that drafts and future pages are built with the options, unlisted pages are never listed
+func TestBuildOptions(t *testing.T) {
+	var logs strings.Builder
+	ix := NewIndexer(Options{SitePath: testExclusionsHugoPath, BuildDrafts: true, BuildFuture: true, Logger: log.New(&logs, "", 0), Verbose: true})
+	pages, excluded, err := ix.readSitePages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := make(map[string]string)
+	for _, page := range pages {
+		reasons[page.Title()] = excluded[page]
+	}
+	if _, found := reasons["Expired"]; found || reasons["Draft"] != "" || reasons["Future"] != "" || reasons["Unlisted"] != "not listed" {
+		t.Errorf("Expected Draft and Future pages, was: %v", reasons)
+	}
+	if !strings.Contains(logs.String(), "[Expired] (expired)") || strings.Contains(logs.String(), "[Draft]") {
+		t.Errorf("Expected the Expired page only logged, was:\n%s", logs.String())
+	}
+}
+
+// checks that the pages hugo does not build do not add terms to the taxonomies
+func TestUnbuiltPageTerms(t *testing.T) {
+	ix := NewIndexer(Options{SitePath: testExclusionsHugoPath, Config: Config{Kinds: []string{"page", "term"}}})
+	pages, _, err := ix.readSitePages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range pages {
+		if page.RelPermalink() == "/tags/drafted/" {
+			t.Errorf("Expected no term page of the draft, was: %s", page.RelPermalink())
+		}
+	}
+}
+
+// checks that every excluded page is logged with the reason
+func TestIndexedPagesLogged(t *testing.T) {
+	var logs strings.Builder
+	config, err := ReadConfig(testExclusionsHugoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix := NewIndexer(Options{SitePath: testExclusionsHugoPath, Config: config, Logger: log.New(&logs, "", 0), Verbose: true})
+	pages, excluded, err := ix.readSitePages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := ix.indexedPages(pages, excluded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != 1 || indexed[0].Title() != "Indexed" {
+		t.Errorf("Expected the page Indexed only, was: %v", indexed)
+	}
+	for _, expected := range []string{"[Draft] (draft)", "[Future] (future)", "[Expired] (expired)", "[Unlisted] (not listed)"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected %q in the log:\n%s", expected, logs.String())
+		}
+	}
+}
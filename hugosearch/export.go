@@ -0,0 +1,304 @@
+package hugosearch
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// formats of the index exported for client-side search
+const (
+	// FormatJSON is an inverted index searched by hugo-search.js, which can be split in shards
+	FormatJSON = "json"
+
+	// FormatLunr is a lunr.js 2.x index, loaded by hugo-search.js with lunr.Index.load
+	FormatLunr = "lunr"
+)
+
+// name of the script that searches the exported index in the browser
+const searchScriptName = "hugo-search.js"
+
+//go:embed hugo-search.js
+var searchScript []byte
+
+// fields of the pages that are searched in the exported index
+var exportedFields = []string{"title", "heading", "description", "keywords", "content"}
+
+// ExportOptions of the index exported for client-side search
+type ExportOptions struct {
+	// FormatJSON or FormatLunr
+	Format string
+
+	// directory of the exported files, the directory of the index path when empty
+	Dir string
+
+	// maximum number of documents per file of the json format, no limit when 0
+	ShardSize int
+}
+
+// exportedDoc is the part of a PageEntry that is displayed in the results of client-side search,
+// the content is searched but not stored to keep the index small
+type exportedDoc struct {
+	ID           string                 `json:"id"`
+	Title        string                 `json:"title,omitempty"`
+	Kind         string                 `json:"kind,omitempty"`
+	Type         string                 `json:"type,omitempty"`
+	Section      string                 `json:"section,omitempty"`
+	Summary      string                 `json:"summary,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	Permalink    string                 `json:"permalink,omitempty"`
+	Keywords     []string               `json:"keywords,omitempty"`
+	Date         string                 `json:"date,omitempty"`
+	LastModified string                 `json:"last_modified,omitempty"`
+	Author       string                 `json:"author,omitempty"`
+	Lang         string                 `json:"lang,omitempty"`
+	Taxonomies   map[string][]string    `json:"taxonomies,omitempty"`
+	Heading      string                 `json:"heading,omitempty"`
+	Parent       string                 `json:"parent,omitempty"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+}
+
+func newExportedDoc(id string, entry *PageEntry) *exportedDoc {
+	return &exportedDoc{
+		ID:           id,
+		Title:        entry.Title,
+		Kind:         entry.Kind,
+		Type:         entry.Type,
+		Section:      entry.Section,
+		Summary:      entry.Summary,
+		Description:  entry.Description,
+		Permalink:    entry.Permalink,
+		Keywords:     entry.Keywords,
+		Date:         formatDate(entry.Date),
+		LastModified: formatDate(entry.LastModified),
+		Author:       entry.Author,
+		Lang:         entry.Lang,
+		Taxonomies:   entry.Taxonomies,
+		Heading:      entry.Heading,
+		Parent:       entry.Parent,
+		Params:       entry.Params,
+	}
+}
+
+// returns the date in RFC 3339 format, or nothing when it is not set
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(time.RFC3339)
+}
+
+// returns the values of the searched fields of the entry, keywords are a list of terms
+func exportedValues(entry *PageEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       entry.Title,
+		"heading":     entry.Heading,
+		"description": entry.Description,
+		"keywords":    entry.Keywords,
+		"content":     entry.Content,
+	}
+}
+
+// Export writes the indexed pages of the site to a static index for client-side search, with
+// hugo-search.js that searches it. The index is named after the index path (e.g. search.json for
+// indexes/search.bleve), multilingual sites get one index per language. Returns the written files.
+func (ix *Indexer) Export(ctx context.Context, eo ExportOptions) ([]string, error) {
+	switch {
+	case eo.Format != FormatJSON && eo.Format != FormatLunr:
+		return nil, fmt.Errorf("unknown export format %q", eo.Format)
+	case eo.ShardSize < 0:
+		return nil, fmt.Errorf("invalid shard size %d", eo.ShardSize)
+	case eo.ShardSize > 0 && eo.Format != FormatJSON:
+		return nil, fmt.Errorf("only the %s format can be sharded", FormatJSON)
+	}
+	dir := eo.Dir
+	if dir == "" {
+		dir = filepath.Dir(ix.opts.IndexPath)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	languages, err := ix.readLanguages()
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(ix.opts.IndexPath)
+	paths := languagePaths(filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base))+".json"), languages)
+
+	var files []string
+	for lang, pages := range languages {
+		docs, values, err := ix.exportedPages(ctx, pages)
+		if err != nil {
+			return nil, err
+		}
+		var written []string
+		if eo.Format == FormatLunr {
+			written, err = writeLunrIndex(paths[lang], lang, docs, values, ix.opts.Config.fieldBoosts())
+		} else {
+			written, err = writeJSONIndex(paths[lang], docs, values, ix.opts.Config.fieldBoosts(), eo.ShardSize)
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, written...)
+	}
+
+	script := filepath.Join(dir, searchScriptName)
+	if err := ioutil.WriteFile(script, searchScript, 0644); err != nil {
+		return nil, err
+	}
+	files = append(files, script)
+	sort.Strings(files)
+	return files, nil
+}
+
+// returns the documents of the pages and the values of their searched fields
+func (ix *Indexer) exportedPages(ctx context.Context, pages page.Pages) ([]*exportedDoc, []map[string]interface{}, error) {
+	var docs []*exportedDoc
+	var values []map[string]interface{}
+	for _, p := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		ids, entries, err := ix.pageEntries(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, entry := range entries {
+			docs = append(docs, newExportedDoc(ids[i], entry))
+			values = append(values, exportedValues(entry))
+		}
+	}
+	return docs, values, nil
+}
+
+// lunrExport is the file of the lunr format: the documents and the lunr index of their refs
+type lunrExport struct {
+	Format string         `json:"format"`
+	Docs   []*exportedDoc `json:"docs"`
+	Index  *lunrIndex     `json:"index"`
+}
+
+// writes the documents and their lunr index of the language to path
+func writeLunrIndex(path string, lang string, docs []*exportedDoc, values []map[string]interface{}, boosts map[string]float64) ([]string, error) {
+	builder := newLunrBuilder(exportedFields, boosts, lang)
+	for i, doc := range docs {
+		builder.add(doc.ID, values[i])
+	}
+	export := lunrExport{Format: FormatLunr, Docs: docs, Index: builder.build()}
+	if err := writeJSONFile(path, export); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+// jsonIndex is the file of the json format: the documents with the postings of their terms, or
+// the shards that hold them
+type jsonIndex struct {
+	Format string    `json:"format"`
+	Fields []string  `json:"fields"`
+	Boosts []float64 `json:"boosts"`
+
+	// files of the shards, relative to the index
+	Shards []string `json:"shards,omitempty"`
+
+	Docs []*exportedDoc `json:"docs,omitempty"`
+
+	// postings of each term, a flat list of document, field and term frequency
+	Terms map[string][]int `json:"terms,omitempty"`
+}
+
+// writes the documents and the postings of their terms to path, or to shards of at most shardSize
+// documents listed by the file at path (e.g. search.0.json for search.json)
+func writeJSONIndex(path string, docs []*exportedDoc, values []map[string]interface{}, boosts map[string]float64, shardSize int) ([]string, error) {
+	index := jsonIndex{Format: FormatJSON, Fields: exportedFields}
+	for _, field := range exportedFields {
+		boost := boosts[field]
+		if boost == 0 {
+			boost = 1
+		}
+		index.Boosts = append(index.Boosts, boost)
+	}
+	if shardSize == 0 || len(docs) <= shardSize {
+		index.Docs, index.Terms = docs, exportedTerms(values)
+		if err := writeJSONFile(path, index); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	files := []string{path}
+	for start := 0; start < len(docs); start += shardSize {
+		end := start + shardSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		shardPath := LanguageIndexPath(path, strconv.Itoa(len(index.Shards)))
+		shard := jsonIndex{Format: FormatJSON, Fields: index.Fields, Boosts: index.Boosts, Docs: docs[start:end], Terms: exportedTerms(values[start:end])}
+		if err := writeJSONFile(shardPath, shard); err != nil {
+			return nil, err
+		}
+		index.Shards = append(index.Shards, filepath.Base(shardPath))
+		files = append(files, shardPath)
+	}
+	if err := writeJSONFile(path, index); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// returns the postings of the terms of the searched fields of the documents
+func exportedTerms(values []map[string]interface{}) map[string][]int {
+	terms := make(map[string][]int)
+	for doc, docValues := range values {
+		for field, name := range exportedFields {
+			frequencies := make(map[string]int)
+			var order []string
+			for _, term := range exportTokenize(docValues[name]) {
+				if frequencies[term] == 0 {
+					order = append(order, term)
+				}
+				frequencies[term]++
+			}
+			for _, term := range order {
+				terms[term] = append(terms[term], doc, field, frequencies[term])
+			}
+		}
+	}
+	return terms
+}
+
+// splits the value in lowercase words of letters and digits, the tokenizer of hugo-search.js
+func exportTokenize(value interface{}) []string {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []string:
+		text = strings.Join(v, " ")
+	}
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// writes the value in compact JSON
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
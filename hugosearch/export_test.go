@@ -0,0 +1,86 @@
+package hugosearch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// exports the test site in the format to a temporary directory, returns the written files
+func exportTestSite(t *testing.T, eo ExportOptions) []string {
+	eo.Dir = t.TempDir()
+	files, err := NewIndexer(testOptions(t, Config{})).Export(context.Background(), eo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// reads the exported file
+func readExport(t *testing.T, path string, v interface{}) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checks that the pages and the postings of their terms are exported with the script
+func TestExportJSON(t *testing.T) {
+	files := exportTestSite(t, ExportOptions{Format: FormatJSON})
+	if len(files) != 2 || filepath.Base(files[0]) != searchScriptName || filepath.Base(files[1]) != "search.json" {
+		t.Fatalf("Expected hugo-search.js and search.json, was: %q", files)
+	}
+
+	var index jsonIndex
+	readExport(t, files[1], &index)
+	if len(index.Docs) != 3 {
+		t.Errorf("Expected: 3 documents, was: %d", len(index.Docs))
+	}
+	if postings := index.Terms["lorem"]; len(postings) == 0 || len(postings)%3 != 0 {
+		t.Errorf("Expected postings of 'lorem', was: %v", postings)
+	}
+}
+
+// checks that the documents are split in shards listed by the index
+func TestExportShards(t *testing.T) {
+	files := exportTestSite(t, ExportOptions{Format: FormatJSON, ShardSize: 2})
+
+	var index jsonIndex
+	readExport(t, filepath.Join(filepath.Dir(files[0]), "search.json"), &index)
+	if expected := []string{"search.0.json", "search.1.json"}; len(index.Shards) != 2 || index.Shards[0] != expected[0] || index.Shards[1] != expected[1] {
+		t.Fatalf("Expected: %q, was: %q", expected, index.Shards)
+	}
+	var shard jsonIndex
+	readExport(t, filepath.Join(filepath.Dir(files[0]), index.Shards[1]), &shard)
+	if len(shard.Docs) != 1 {
+		t.Errorf("Expected: 1 document in last shard, was: %d", len(shard.Docs))
+	}
+}
+
+// checks that the lunr index refers to the exported documents
+func TestExportLunr(t *testing.T) {
+	files := exportTestSite(t, ExportOptions{Format: FormatLunr})
+
+	var export struct {
+		Docs  []exportedDoc `json:"docs"`
+		Index lunrIndex     `json:"index"`
+	}
+	readExport(t, files[1], &export)
+	if export.Index.Version != lunrVersion || len(export.Index.FieldVectors) != len(export.Docs)*len(exportedFields) {
+		t.Errorf("Expected a field vector per document and field, was: %d", len(export.Index.FieldVectors))
+	}
+}
+
+// checks that invalid export options are reported
+func TestExportInvalid(t *testing.T) {
+	for _, eo := range []ExportOptions{{Format: "xml"}, {Format: FormatLunr, ShardSize: 10}, {Format: FormatJSON, ShardSize: -1}} {
+		if _, err := NewIndexer(testOptions(t, Config{})).Export(context.Background(), eo); err == nil {
+			t.Errorf("Expected error for %+v", eo)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package hugosearch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// file of the index directory that lists its generations, the current one first, the one that
+// is served, followed by the previous ones
+const generationsFile = "generations"
+
+// format of the names of the generations
+const generationFormat = "2006-01-02T15-04-05.000000000"
+
+// The index path is a directory of generations: each build writes the indexes of the languages
+// to a new directory, named after the time of the build, that becomes the current generation
+// when the generations file lists it first. The indexes that are served are never moved or modified.
+
+// CurrentIndexPath returns the path of the index of the current generation at indexPath, the indexes
+// of the languages are next to it (see LanguageIndexPath). An index path without generations, like
+// the indexes of previous versions, is returned as it is.
+func CurrentIndexPath(indexPath string) (string, error) {
+	generations, err := readGenerations(indexPath)
+	if err != nil || len(generations) == 0 {
+		return indexPath, err
+	}
+	return generationIndexPath(indexPath, generations[0]), nil
+}
+
+// returns the path of the index in the generation of the index directory at indexPath
+func generationIndexPath(indexPath string, generation string) string {
+	return filepath.Join(indexPath, generation, filepath.Base(indexPath))
+}
+
+// returns the generations listed at indexPath, the current one first
+func readGenerations(indexPath string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(indexPath, generationsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// lists the generations at indexPath, the current one first. The generations file is replaced at once,
+// it is written next to it first.
+func writeGenerations(indexPath string, generations []string) error {
+	file, err := ioutil.TempFile(indexPath, "."+generationsFile+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(strings.Join(generations, "\n") + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return rename(file.Name(), filepath.Join(indexPath, generationsFile))
+}
+
+// renames the files and directories, replaced by tests to make them fail
+var rename = os.Rename
+
+// returns the directory where the next generation of the indexes at indexPath is built, in the
+// index directory. Remove it when done.
+func newBuildDir(indexPath string) (string, error) {
+	if err := os.MkdirAll(indexPath, 0700); err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(indexPath, ".build-")
+}
+
+// makes the generation built in buildDir the current one at indexPath, the current generation becomes
+// the previous one. Returns the path of its index.
+func publishGeneration(indexPath string, buildDir string) (string, error) {
+	generations, err := readGenerations(indexPath)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	generation := now.Format(generationFormat)
+	for isDir(filepath.Join(indexPath, generation)) {
+		now = now.Add(time.Nanosecond)
+		generation = now.Format(generationFormat)
+	}
+	if err := rename(buildDir, filepath.Join(indexPath, generation)); err != nil {
+		return "", err
+	}
+	if err := writeGenerations(indexPath, append([]string{generation}, generations...)); err != nil {
+		return "", err
+	}
+	return generationIndexPath(indexPath, generation), nil
+}
+
+// checks if there is a directory at path
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// copies the files of the index at src to dst, an index of a previous version also holds the generations
+func copyIndex(src string, dst string) error {
+	for _, name := range []string{"index_meta.json", "store"} {
+		if _, err := os.Stat(filepath.Join(src, name)); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		if err := copyDir(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copies the files of the directory src to dst
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checks that the index has the documents of the build and that they can be searched
+func validateIndex(index bleve.Index, ids []string) error {
+	count, err := index.DocCount()
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(ids)) {
+		return fmt.Errorf("invalid index: %d documents, expected %d", count, len(ids))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	result, err := index.Search(bleve.NewSearchRequest(bleve.NewDocIDQuery(ids[:1])))
+	if err != nil {
+		return fmt.Errorf("invalid index: %v", err)
+	}
+	if len(result.Hits) != 1 {
+		return fmt.Errorf("invalid index: document %s not found", ids[0])
+	}
+	return nil
+}
+
+// returns the names of the directories of the generations at indexPath, listed or not
+func findGenerations(indexPath string) ([]string, error) {
+	infos, err := ioutil.ReadDir(indexPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var generations []string
+	for _, info := range infos {
+		if _, err := time.Parse(generationFormat, info.Name()); err == nil && info.IsDir() {
+			generations = append(generations, info.Name())
+		}
+	}
+	return generations, nil
+}
+
+// removes the generations at indexPath beyond the current one and the previous ones kept by the options,
+// like the generations rolled back. Call it once the current generation is served: a generation still
+// open is left for the next build.
+func (ix *Indexer) removeOldGenerations(indexPath string) error {
+	generations, err := readGenerations(indexPath)
+	if err != nil || len(generations) == 0 {
+		return err
+	}
+	if len(generations) > 1+ix.opts.Generations {
+		generations = generations[:1+ix.opts.Generations]
+		if err := writeGenerations(indexPath, generations); err != nil {
+			return err
+		}
+	}
+	kept := make(map[string]bool)
+	for _, generation := range generations {
+		kept[generation] = true
+	}
+	found, err := findGenerations(indexPath)
+	if err != nil {
+		return err
+	}
+	for _, generation := range found {
+		if kept[generation] {
+			continue
+		}
+		path := filepath.Join(indexPath, generation)
+		if err := os.RemoveAll(path); err != nil {
+			ix.log.Println("WARN: Index not removed:", err)
+			continue
+		}
+		if ix.verbose {
+			ix.log.Println("Removed Index:", path)
+		}
+	}
+	return ix.removeIndexFiles(indexPath)
+}
+
+// removes the files of an index of a previous version at indexPath, which is replaced by the generations
+func (ix *Indexer) removeIndexFiles(indexPath string) error {
+	if !isIndex(indexPath) {
+		return nil
+	}
+	for _, name := range []string{"store", "index_meta.json"} {
+		if err := os.RemoveAll(filepath.Join(indexPath, name)); err != nil {
+			ix.log.Println("WARN: Index not removed:", err)
+			return nil
+		}
+	}
+	if ix.verbose {
+		ix.log.Println("Removed Index:", indexPath)
+	}
+	return nil
+}
+
+// Rollback makes the previous generation at indexPath current, with the indexes of its languages.
+// Returns the path of its index. The generation rolled back is removed by the next build, which builds
+// a new generation: a server that rebuilds the index supersedes the rollback.
+func Rollback(indexPath string) (string, error) {
+	generations, err := readGenerations(indexPath)
+	if err != nil {
+		return "", err
+	}
+	if len(generations) < 2 {
+		return "", fmt.Errorf("no previous index of %s", indexPath)
+	}
+	if !isDir(filepath.Join(indexPath, generations[1])) {
+		return "", fmt.Errorf("previous index of %s not found: %s", indexPath, generations[1])
+	}
+	if err := writeGenerations(indexPath, generations[1:]); err != nil {
+		return "", err
+	}
+	return generationIndexPath(indexPath, generations[1]), nil
+}
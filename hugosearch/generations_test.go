@@ -0,0 +1,209 @@
+package hugosearch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns the options of the test site keeping the previous indexes
+func generationsOptions(t *testing.T, config Config, generations int) Options {
+	opts := testOptions(t, config)
+	opts.Generations = generations
+	return opts
+}
+
+// returns the number of documents of the current index at path
+func docCount(t *testing.T, path string) uint64 {
+	index := openIndex(t, path)
+	defer index.Close()
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// returns the name of the current generation at indexPath
+func testGeneration(t *testing.T, indexPath string) string {
+	generations, err := readGenerations(indexPath)
+	if err != nil || len(generations) == 0 {
+		t.Fatalf("Expected a current generation, was: %v (%v)", generations, err)
+	}
+	return generations[0]
+}
+
+// checks that the previous generations beyond the options are removed and that no build directory is left
+func TestBuildGenerations(t *testing.T) {
+	opts := generationsOptions(t, Config{}, 1)
+	for i := 0; i < 3; i++ {
+		buildTestIndex(t, opts)
+	}
+	generations, err := readGenerations(opts.IndexPath)
+	if err != nil || len(generations) != 2 {
+		t.Errorf("Expected the current and 1 previous generation, was: %v (%v)", generations, err)
+	}
+	infos, _ := ioutil.ReadDir(opts.IndexPath)
+	if len(infos) != 3 {
+		t.Errorf("Expected the generations and their list only, was: %d files", len(infos))
+	}
+	if docCount(t, opts.IndexPath) == 0 {
+		t.Errorf("Expected documents in the index")
+	}
+}
+
+// checks that a build that fails leaves the current generation as it was
+func TestBuildFailedKeepsIndex(t *testing.T) {
+	opts := generationsOptions(t, Config{}, 1)
+	buildTestIndex(t, opts)
+	expected := docCount(t, opts.IndexPath)
+	generation := testGeneration(t, opts.IndexPath)
+
+	opts.Config.SplitHeadings = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewIndexer(opts).Build(ctx); err == nil {
+		t.Fatal("Expected build cancelled")
+	}
+	if actual := docCount(t, opts.IndexPath); actual != expected || testGeneration(t, opts.IndexPath) != generation {
+		t.Errorf("Expected: %d documents in %s, was: %d", expected, generation, actual)
+	}
+	if generations, _ := findGenerations(opts.IndexPath); len(generations) != 1 {
+		t.Errorf("Expected no new generation, was: %v", generations)
+	}
+}
+
+// checks that the previous generation is made current until there is none, and that the generation
+// rolled back is removed by the next build
+func TestRollback(t *testing.T) {
+	opts := generationsOptions(t, Config{}, 2)
+	buildTestIndex(t, opts)
+	expected := docCount(t, opts.IndexPath)
+	opts.Config.SplitHeadings = true
+	buildTestIndex(t, opts)
+	rolledBack := testGeneration(t, opts.IndexPath)
+	if docCount(t, opts.IndexPath) == expected {
+		t.Fatal("Expected the sections of the pages in the index")
+	}
+
+	path, err := Rollback(opts.IndexPath)
+	if current, _ := CurrentIndexPath(opts.IndexPath); err != nil || path != current || strings.Contains(path, rolledBack) {
+		t.Fatalf("Expected %s rolled back, was: %s (%v)", opts.IndexPath, path, err)
+	}
+	if actual := docCount(t, opts.IndexPath); actual != expected {
+		t.Errorf("Expected: %d documents, was: %d", expected, actual)
+	}
+	if _, err := Rollback(opts.IndexPath); err == nil {
+		t.Errorf("Expected no previous index")
+	}
+
+	buildTestIndex(t, opts)
+	if _, err := os.Stat(filepath.Join(opts.IndexPath, rolledBack)); !os.IsNotExist(err) {
+		t.Errorf("Expected the generation rolled back removed, was: %v", err)
+	}
+}
+
+// makes the renames of the files at paths starting with prefix fail until the test ends
+func failRenames(t *testing.T, prefix string) {
+	t.Cleanup(func() { rename = os.Rename })
+	rename = func(oldpath string, newpath string) error {
+		if strings.HasPrefix(oldpath, prefix) {
+			return fmt.Errorf("cannot rename %s", oldpath)
+		}
+		return os.Rename(oldpath, newpath)
+	}
+}
+
+// checks that the current generation is kept when the built generation cannot be published
+func TestPublishGenerationFailed(t *testing.T) {
+	opts := generationsOptions(t, Config{}, 1)
+	buildTestIndex(t, opts)
+	expected := docCount(t, opts.IndexPath)
+	generation := testGeneration(t, opts.IndexPath)
+
+	opts.Config.SplitHeadings = true
+	failRenames(t, filepath.Join(opts.IndexPath, ".build-"))
+	if err := NewIndexer(opts).Build(context.Background()); err == nil {
+		t.Fatal("Expected build failed")
+	}
+	if actual := docCount(t, opts.IndexPath); actual != expected || testGeneration(t, opts.IndexPath) != generation {
+		t.Errorf("Expected: %d documents in %s, was: %d", expected, generation, actual)
+	}
+	if infos, _ := ioutil.ReadDir(opts.IndexPath); len(infos) != 2 {
+		t.Errorf("Expected the generation and its list only, was: %d files", len(infos))
+	}
+}
+
+// checks that the current generation is kept when the generations file cannot be replaced
+func TestRollbackFailed(t *testing.T) {
+	opts := generationsOptions(t, Config{}, 1)
+	buildTestIndex(t, opts)
+	buildTestIndex(t, opts)
+	generation := testGeneration(t, opts.IndexPath)
+
+	failRenames(t, filepath.Join(opts.IndexPath, "."+generationsFile+"-"))
+	if _, err := Rollback(opts.IndexPath); err == nil {
+		t.Fatal("Expected rollback failed")
+	}
+	if testGeneration(t, opts.IndexPath) != generation {
+		t.Errorf("Expected the current generation kept")
+	}
+	if infos, _ := ioutil.ReadDir(opts.IndexPath); len(infos) != 3 {
+		t.Errorf("Expected the generations and their list only, was: %d files", len(infos))
+	}
+}
+
+// checks that the languages of a multilingual site are built in the same generation, and that the
+// languages removed from the site are kept in the previous one
+func TestBuildGenerationLanguages(t *testing.T) {
+	opts := testMultilingualOptions(t)
+	opts.Generations = 1
+	buildTestIndex(t, opts)
+	previous, _ := CurrentIndexPath(opts.IndexPath)
+
+	opts.SitePath = testHugoPath
+	buildTestIndex(t, opts)
+	current, _ := CurrentIndexPath(opts.IndexPath)
+	if languages, err := findLanguageIndexes(current); err != nil || len(languages) != 0 || !isIndex(current) {
+		t.Errorf("Expected a single index, was: %v (%v)", languages, err)
+	}
+	if languages, err := findLanguageIndexes(previous); err != nil || len(languages) != 3 {
+		t.Errorf("Expected the languages in the previous generation, was: %v (%v)", languages, err)
+	}
+}
+
+// checks that an index of a previous version at the index path is updated into a generation
+func TestBuildPreviousVersion(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+	current, _ := CurrentIndexPath(opts.IndexPath)
+	previousVersion := filepath.Join(t.TempDir(), "search.bleve")
+	if err := copyIndex(current, previousVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.IndexPath = previousVersion
+	buildTestIndex(t, opts)
+	if isIndex(previousVersion) {
+		t.Errorf("Expected the index of the previous version removed")
+	}
+	if docCount(t, previousVersion) == 0 {
+		t.Errorf("Expected documents in the index")
+	}
+}
+
+// checks that the document count of the index is validated
+func TestValidateIndex(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+	index := openIndex(t, opts.IndexPath)
+	defer index.Close()
+
+	if err := validateIndex(index, []string{"/page1/"}); err == nil {
+		t.Errorf("Expected invalid document count")
+	}
+}
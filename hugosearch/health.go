@@ -0,0 +1,134 @@
+package hugosearch
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/blevesearch/bleve"
+)
+
+// names of the checks of the readiness
+const (
+	checkOpened    = "opened"
+	checkDocuments = "documents"
+	checkWarmUp    = "warmup"
+	checkReload    = "reload"
+	checkSwap      = "swap"
+)
+
+// healthHandler answers GET /healthz as long as the process is alive, without looking at the indexes
+type healthHandler struct {
+	server *Server
+}
+
+func newHealthHandler(server *Server) *healthHandler {
+	return &healthHandler{server: server}
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.server.writeJSON(w, map[string]string{"status": "alive"})
+}
+
+// readyHandler answers GET /readyz with the checks of the readiness of the server, and status 503 when
+// one of them fails: each index is opened, has documents and answered a warm-up query, the last reload
+// succeeded and the indexes of several languages are not being swapped one after the other
+type readyHandler struct {
+	server *Server
+}
+
+func newReadyHandler(server *Server) *readyHandler {
+	return &readyHandler{server: server}
+}
+
+// readyResponse is the response of GET /readyz
+type readyResponse struct {
+	Ready  bool         `json:"ready"`
+	Checks []readyCheck `json:"checks"`
+}
+
+// readyCheck is a check of the readiness, of an index or of the server when the index is empty
+type readyCheck struct {
+	Name   string `json:"name"`
+	Index  string `json:"index,omitempty"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (h *readyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response := h.server.readiness()
+	code := http.StatusOK
+	if !response.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	h.server.writeJSONStatus(w, response, code)
+}
+
+// returns the checks of the readiness of the served indexes, sorted by name, and of the reloads
+func (s *Server) readiness() *readyResponse {
+	response := &readyResponse{Ready: true}
+	add := func(name string, index string, err error, detail string) {
+		check := readyCheck{Name: name, Index: index, OK: err == nil, Detail: detail}
+		if err != nil {
+			check.Detail = err.Error()
+			response.Ready = false
+		}
+		response.Checks = append(response.Checks, check)
+	}
+
+	langs := make([]string, 0, len(s.indexes))
+	for lang := range s.indexes {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		index, name := s.indexes[lang], s.servedIndexName(lang)
+		count, err := index.DocCount()
+		add(checkOpened, name, err, "")
+		if err != nil {
+			continue
+		}
+		if count == 0 {
+			err = fmt.Errorf("no documents")
+		}
+		add(checkDocuments, name, err, fmt.Sprintf("%d documents", count))
+		add(checkWarmUp, name, index.warmUpError(), "")
+	}
+
+	swapping, err := s.reloader.state()
+	add(checkReload, "", err, "")
+	if swapping {
+		err = fmt.Errorf("swapping the indexes of the languages")
+	} else {
+		err = nil
+	}
+	add(checkSwap, "", err, "")
+	return response
+}
+
+// returns the name the index of the language is registered with
+func (s *Server) servedIndexName(lang string) string {
+	if lang == "" {
+		return filepath.Base(s.opts.IndexPath)
+	}
+	return lang
+}
+
+// answers a query with the index, so that the first queries of the clients do not pay for loading it
+func warmUp(index bleve.Index) error {
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), 1, 0, false)
+	request.Fields = hitFields
+	if _, err := index.Search(request); err != nil {
+		return fmt.Errorf("warm-up query failed: %v", err)
+	}
+	return nil
+}
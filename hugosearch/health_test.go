@@ -0,0 +1,101 @@
+package hugosearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sends the GET request to the path of the handler, returns the status code and the body
+func getHealth(t *testing.T, handler http.Handler, path string) (int, *readyResponse) {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+	handler.ServeHTTP(recorder, request)
+
+	var response readyResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	return recorder.Code, &response
+}
+
+// returns the check of the response, nil if not found
+func findCheck(response *readyResponse, name string) *readyCheck {
+	for i, check := range response.Checks {
+		if check.Name == name {
+			return &response.Checks[i]
+		}
+	}
+	return nil
+}
+
+func TestHealthz(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	if code, _ := getHealth(t, server.Handler(), "/healthz"); code != http.StatusOK {
+		t.Errorf("Expected status %d, was: %d", http.StatusOK, code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	code, response := getHealth(t, server.Handler(), "/readyz")
+	if code != http.StatusOK || !response.Ready {
+		t.Fatalf("Expected ready, was: %d %+v", code, response)
+	}
+	for _, name := range []string{checkOpened, checkDocuments, checkWarmUp, checkReload, checkSwap} {
+		if check := findCheck(response, name); check == nil || !check.OK {
+			t.Errorf("Expected check %s to pass, was: %+v", name, check)
+		}
+	}
+	if check := findCheck(response, checkDocuments); check.Index != testIndexName || check.Detail != "3 documents" {
+		t.Errorf("Expected 3 documents in %s, was: %+v", testIndexName, check)
+	}
+}
+
+// checks that the server is not ready while the languages are swapped, and after a failed reload
+func TestReadyzNotReady(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+	handler := server.Handler()
+
+	server.reloader.setSwapping(true)
+	code, response := getHealth(t, handler, "/readyz")
+	if code != http.StatusServiceUnavailable || response.Ready || findCheck(response, checkSwap).OK {
+		t.Errorf("Expected not ready while swapping, was: %d %+v", code, response)
+	}
+	server.reloader.setSwapping(false)
+
+	server.reloader.indexer.opts.SitePath = "../missing"
+	if err := server.Reload(context.Background()); err == nil {
+		t.Fatal("Expected reload to fail")
+	}
+	code, response = getHealth(t, handler, "/readyz")
+	if check := findCheck(response, checkReload); code != http.StatusServiceUnavailable || check.OK || check.Detail == "" {
+		t.Errorf("Expected failed reload, was: %d %+v", code, response)
+	}
+
+	server.reloader.indexer.opts.SitePath = testHugoPath
+	if err := server.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, response = getHealth(t, handler, "/readyz"); code != http.StatusOK {
+		t.Errorf("Expected ready after reload, was: %d %+v", code, response)
+	}
+}
+
+func TestReadyzClosedIndex(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+	server.indexes[""].IndexAlias.Close()
+
+	code, response := getHealth(t, server.Handler(), "/readyz")
+	if check := findCheck(response, checkOpened); code != http.StatusServiceUnavailable || check.OK {
+		t.Errorf("Expected closed index, was: %d %+v", code, response)
+	}
+}
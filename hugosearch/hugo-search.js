@@ -0,0 +1,510 @@
+"use strict";
+
+// hugo-search.js searches the index written by `hugo-search export` in the browser. It answers
+// the bleve search requests of search.js with bleve's search results: when jQuery is loaded, POST
+// requests to a .json url are answered from that file, so only the search url changes:
+//
+//     var searchURL = "/search/search.json";
+//
+// Without jQuery, hugoSearch.load(url) returns a promise of the index, whose search(request)
+// returns the results. Indexes of the lunr format need lunr.js 2.x loaded first.
+var hugoSearch = (function() {
+
+    var loaded = {};
+
+    // loads the index at url, once. The shards of a sharded index are fetched one after the other:
+    // the index is returned with the first one and searches the shards loaded so far, which the
+    // status of its results counts like bleve's. index.loaded resolves when all are loaded.
+    function load(url) {
+        if (!(url in loaded)) {
+            loaded[url] = fetchJSON(url).then(function(data) {
+                if (data.format === "lunr") {
+                    return new Index(data.docs, lunrSearcher(data));
+                }
+                var index = new Index([], jsonSearcher(data));
+                var shards = data.shards || [];
+                if (shards.length === 0) {
+                    index.add(data);
+                    return index;
+                }
+                var base = url.substring(0, url.lastIndexOf("/") + 1);
+                var fetchShard = function(shard) {
+                    return fetchJSON(base + shard).then(function(part) {
+                        index.add(part);
+                        index.parts++;
+                    });
+                };
+                index.shards = shards.length;
+                index.parts = 0;
+                var first = fetchShard(shards[0]);
+                index.loaded = shards.slice(1).reduce(function(previous, shard) {
+                    return previous.then(function() {
+                        return fetchShard(shard);
+                    });
+                }, first).catch(function(err) {
+                    index.failed = index.shards - index.parts;
+                    throw err;
+                });
+                index.loaded.catch(function() {});
+                return first.then(function() {
+                    return index;
+                });
+            });
+        }
+        return loaded[url];
+    }
+
+    function fetchJSON(url) {
+        return fetch(url).then(function(response) {
+            if (!response.ok) {
+                throw new Error(response.status + " " + response.statusText);
+            }
+            return response.json();
+        });
+    }
+
+    // splits text in lowercase words of letters and digits, like the exporter
+    function tokenize(text) {
+        return String(text).toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function(word) {
+            return word.length > 0;
+        });
+    }
+
+    // searches the postings of the json format, the shards are added as they are loaded and their
+    // documents are numbered across shards
+    function jsonSearcher(data) {
+        var fields = data.fields;
+        var boosts = data.boosts;
+        var docCount = 0;
+        var terms = Object.create(null);
+
+        // scores the documents with all words in the field (or any field) by tf-idf,
+        // the last word matches the terms it starts with when prefix is set
+        function search(words, field, prefix) {
+            var scores = null;
+            words.forEach(function(word, w) {
+                var matching = prefix && w === words.length - 1 ? Object.keys(terms).filter(function(term) {
+                    return term.indexOf(word) === 0;
+                }) : [word];
+                var wordScores = {};
+                matching.forEach(function(term) {
+                    var postings = terms[term] || [];
+                    var found = {};
+                    for (var i = 0; i < postings.length; i += 3) {
+                        found[postings[i]] = true;
+                    }
+                    var idf = Math.log(1 + docCount / Object.keys(found).length);
+                    for (var j = 0; j < postings.length; j += 3) {
+                        if (field && fields[postings[j + 1]] !== field) {
+                            continue;
+                        }
+                        var score = boosts[postings[j + 1]] * (1 + Math.log(postings[j + 2])) * idf;
+                        wordScores[postings[j]] = (wordScores[postings[j]] || 0) + score;
+                    }
+                });
+                scores = scores === null ? wordScores : intersect(scores, wordScores);
+            });
+            return scores || {};
+        }
+
+        return {
+            fields: fields,
+            add: function(part) {
+                var offset = docCount;
+                docCount += (part.docs || []).length;
+                for (var term in part.terms) {
+                    var postings = terms[term] || (terms[term] = []);
+                    var add = part.terms[term];
+                    for (var i = 0; i < add.length; i += 3) {
+                        postings.push(add[i] + offset, add[i + 1], add[i + 2]);
+                    }
+                }
+            },
+            queryString: function(q) {
+                return evaluateQueryString(this, q);
+            },
+            match: function(text, field, all) {
+                var words = tokenize(text);
+                if (all) {
+                    return search(words, field, false);
+                }
+                var scores = {};
+                words.forEach(function(word) {
+                    scores = union(scores, search([word], field, false));
+                });
+                return scores;
+            },
+            terms: function(text, field, prefix) {
+                return search(tokenize(text), field, prefix);
+            }
+        };
+    }
+
+    // searches the lunr index of the documents, lunr parses the query strings
+    function lunrSearcher(data) {
+        var index = lunr.Index.load(data.index);
+        var positions = {};
+        data.docs.forEach(function(doc, i) {
+            positions[doc.id] = i;
+        });
+
+        function search(q) {
+            var scores = {};
+            index.search(q).forEach(function(result) {
+                scores[positions[result.ref]] = result.score;
+            });
+            return scores;
+        }
+
+        function clause(words, field, all, prefix) {
+            return words.map(function(word, w) {
+                return (all ? "+" : "") + (field ? field + ":" : "") + word + (prefix && w === words.length - 1 ? "*" : "");
+            }).join(" ");
+        }
+
+        return {
+            fields: data.index.fields,
+            queryString: search,
+            match: function(text, field, all) {
+                return search(clause(tokenize(text), field, all, false));
+            },
+            terms: function(text, field, prefix) {
+                return search(clause(tokenize(text), field, true, prefix));
+            }
+        };
+    }
+
+    // evaluates a query string of bleve's syntax: +word must match, -word must not, field:word
+    // searches a field or filters on its value, word* matches terms starting with word and
+    // "a phrase" matches all its words
+    function evaluateQueryString(searcher, q) {
+        var clauses = [];
+        var re = /([+-]?)(?:([\w.]+):)?("[^"]*"|\S+)/g;
+        var m;
+        while ((m = re.exec(q)) !== null) {
+            var text = m[3];
+            var phrase = text.charAt(0) === "\"";
+            var prefix = !phrase && /\*$/.test(text);
+            if (phrase) {
+                text = text.slice(1, -1);
+            }
+            var scores;
+            if (m[2] && searcher.fields.indexOf(m[2]) < 0) {
+                scores = searcher.filter(m[2], text.replace(/\*$/, ""), prefix);
+            } else {
+                scores = searcher.terms(text, m[2], prefix);
+            }
+            clauses.push({ occur: m[1], scores: scores });
+        }
+
+        var must = null;
+        var should = {};
+        var not = {};
+        clauses.forEach(function(clause) {
+            if (clause.occur === "+") {
+                must = must === null ? clause.scores : intersect(must, clause.scores);
+            } else if (clause.occur === "-") {
+                not = union(not, clause.scores);
+            } else {
+                should = union(should, clause.scores);
+            }
+        });
+        var scores = must === null ? should : must;
+        var matches = {};
+        for (var doc in scores) {
+            if (!(doc in not)) {
+                matches[doc] = scores[doc] + (must !== null && doc in should ? should[doc] : 0);
+            }
+        }
+        return matches;
+    }
+
+    function intersect(a, b) {
+        var scores = {};
+        for (var doc in a) {
+            if (doc in b) {
+                scores[doc] = a[doc] + b[doc];
+            }
+        }
+        return scores;
+    }
+
+    function union(a, b) {
+        var scores = {};
+        for (var doc in a) {
+            scores[doc] = a[doc];
+        }
+        for (var doc2 in b) {
+            scores[doc2] = (scores[doc2] || 0) + b[doc2];
+        }
+        return scores;
+    }
+
+    // flattens nested objects to dotted names like bleve's fields, e.g. taxonomies.tags
+    function flatten(doc, prefix, fields) {
+        fields = fields || {};
+        for (var name in doc) {
+            var value = doc[name];
+            if (value !== null && typeof value === "object" && !Array.isArray(value)) {
+                flatten(value, prefix + name + ".", fields);
+            } else {
+                fields[prefix + name] = value;
+            }
+        }
+        return fields;
+    }
+
+    // Index answers bleve search requests from the stored documents, the searcher finds the text
+    function Index(docs, searcher) {
+        this.docs = docs.map(function(doc) {
+            return flatten(doc, "", {});
+        });
+        this.searcher = searcher;
+        searcher.filter = this.filter.bind(this);
+        this.shards = 1;
+        this.parts = 1;
+        this.failed = 0;
+        this.loaded = Promise.resolve();
+    }
+
+    // adds a shard of the json format, its documents follow those of the shards loaded before
+    Index.prototype.add = function(part) {
+        var docs = this.docs;
+        (part.docs || []).forEach(function(doc) {
+            docs.push(flatten(doc, "", {}));
+        });
+        this.searcher.add(part);
+    };
+
+    // returns the documents whose field has the value (or starts with it), case insensitive
+    Index.prototype.filter = function(field, value, prefix) {
+        var scores = {};
+        value = String(value).toLowerCase();
+        this.docs.forEach(function(doc, i) {
+            var values = [].concat(doc[field] === undefined ? [] : doc[field]);
+            for (var j = 0; j < values.length; j++) {
+                var v = String(values[j]).toLowerCase();
+                if (v === value || (prefix && v.indexOf(value) === 0)) {
+                    scores[i] = 1;
+                    return;
+                }
+            }
+        });
+        return scores;
+    };
+
+    // returns the documents whose field is in the range, dates or numbers
+    Index.prototype.range = function(field, min, max, parse) {
+        var scores = {};
+        this.docs.forEach(function(doc, i) {
+            if (doc[field] === undefined) {
+                return;
+            }
+            var v = parse(doc[field]);
+            if ((min === undefined || v >= parse(min)) && (max === undefined || v < parse(max))) {
+                scores[i] = 1;
+            }
+        });
+        return scores;
+    };
+
+    // evaluates a bleve query to the scores of the matching documents, by position
+    Index.prototype.evaluate = function(q) {
+        var self = this;
+        var scores;
+        var boost = q.boost === undefined ? 1 : q.boost;
+        if ("conjuncts" in q) {
+            scores = null;
+            q.conjuncts.forEach(function(c) {
+                var s = self.evaluate(c);
+                scores = scores === null ? s : intersect(scores, s);
+            });
+            scores = scores || {};
+        } else if ("disjuncts" in q) {
+            scores = {};
+            q.disjuncts.forEach(function(d) {
+                scores = union(scores, self.evaluate(d));
+            });
+        } else if ("query" in q) {
+            scores = this.searcher.queryString(q.query);
+        } else if ("match_all" in q) {
+            scores = {};
+            this.docs.forEach(function(doc, i) {
+                scores[i] = 1;
+            });
+        } else if ("match_none" in q) {
+            scores = {};
+        } else if ("match" in q || "match_phrase" in q) {
+            var text = "match" in q ? q.match : q.match_phrase;
+            if (q.field && this.searcher.fields.indexOf(q.field) < 0) {
+                scores = this.filter(q.field, text, false);
+            } else {
+                scores = this.searcher.match(text, q.field, "match_phrase" in q || q.operator === "and");
+            }
+        } else if ("term" in q) {
+            scores = this.filter(q.field, q.term, false);
+        } else if ("prefix" in q) {
+            scores = this.filter(q.field, q.prefix, true);
+        } else if ("start" in q || "end" in q) {
+            scores = this.range(q.field, q.start, q.end, Date.parse);
+        } else if ("min" in q || "max" in q) {
+            scores = this.range(q.field, q.min, q.max, Number);
+        } else {
+            throw new Error("unsupported query: " + JSON.stringify(q));
+        }
+        for (var doc in scores) {
+            scores[doc] *= boost;
+        }
+        return scores;
+    };
+
+    // returns the bleve search result of the request
+    Index.prototype.search = function(request) {
+        var self = this;
+        var started = Date.now();
+        var scores = this.evaluate(request.query || { match_all: {} });
+        var matches = Object.keys(scores).map(Number);
+        var sort = request.sort || ["-_score"];
+        matches.sort(function(a, b) {
+            for (var i = 0; i < sort.length; i++) {
+                var field = sort[i].replace(/^-/, "");
+                var va = field === "_score" ? scores[a] : self.docs[a][field];
+                var vb = field === "_score" ? scores[b] : self.docs[b][field];
+                if (va !== vb) {
+                    var cmp = vb === undefined || va < vb ? -1 : 1;
+                    return sort[i].charAt(0) === "-" ? -cmp : cmp;
+                }
+            }
+            return a - b;
+        });
+
+        var from = request.from || 0;
+        var size = request.size === undefined ? 10 : request.size;
+        var hits = matches.slice(from, from + size).map(function(i) {
+            return self.hit(i, scores[i], request);
+        });
+        var facets = {};
+        for (var name in request.facets || {}) {
+            facets[name] = this.facet(request.facets[name], matches);
+        }
+        return {
+            status: { total: this.shards, failed: this.failed, successful: this.parts },
+            request: request,
+            hits: hits,
+            total_hits: matches.length,
+            max_score: matches.reduce(function(max, i) {
+                return Math.max(max, scores[i]);
+            }, 0),
+            took: (Date.now() - started) * 1e6,
+            facets: facets
+        };
+    };
+
+    // returns the hit of a document with the requested fields, the content is not stored so its
+    // fragment highlights the words of the query in the description or summary
+    Index.prototype.hit = function(i, score, request) {
+        var doc = this.docs[i];
+        var hit = { index: "hugo-search", id: doc.id, score: score, fields: {} };
+        (request.fields || []).forEach(function(field) {
+            for (var name in doc) {
+                if (field === "*" || field === name) {
+                    hit.fields[name] = doc[name];
+                }
+            }
+        });
+        if (request.highlight) {
+            var text = doc.description || doc.summary || "";
+            var words = tokenize(queryText(request.query));
+            var marked = escapeHTML(text).replace(/[\p{L}\p{N}]+/gu, function(word) {
+                return words.indexOf(word.toLowerCase()) < 0 ? word : "<mark>" + word + "</mark>";
+            });
+            hit.fragments = {};
+            (request.highlight.fields || ["content"]).forEach(function(field) {
+                hit.fragments[field] = [marked];
+            });
+        }
+        return hit;
+    };
+
+    // returns the terms or date ranges facet of the matching documents
+    Index.prototype.facet = function(facet, matches) {
+        var self = this;
+        var result = { field: facet.field, total: 0, missing: 0, other: 0 };
+        if (facet.date_ranges) {
+            result.date_ranges = facet.date_ranges.map(function(dr) {
+                var count = 0;
+                matches.forEach(function(i) {
+                    var v = Date.parse(self.docs[i][facet.field]);
+                    if ((!dr.start || v >= Date.parse(dr.start)) && (!dr.end || v < Date.parse(dr.end))) {
+                        count++;
+                    }
+                });
+                result.total += count;
+                return { name: dr.name, start: dr.start, end: dr.end, count: count };
+            }).filter(function(dr) {
+                return dr.count > 0;
+            });
+            return result;
+        }
+
+        var counts = {};
+        matches.forEach(function(i) {
+            var value = self.docs[i][facet.field];
+            if (value === undefined || value === "") {
+                result.missing++;
+                return;
+            }
+            [].concat(value).forEach(function(term) {
+                counts[term] = (counts[term] || 0) + 1;
+                result.total++;
+            });
+        });
+        var terms = Object.keys(counts).map(function(term) {
+            return { term: term, count: counts[term] };
+        }).sort(function(a, b) {
+            return b.count - a.count || (a.term < b.term ? -1 : 1);
+        });
+        result.terms = terms.slice(0, facet.size);
+        result.other = terms.slice(facet.size).reduce(function(sum, term) {
+            return sum + term.count;
+        }, 0);
+        return result;
+    };
+
+    // returns the text of the query strings and matches of a query, for highlighting
+    function queryText(q) {
+        if (!q) {
+            return "";
+        }
+        return [].concat(q.query || q.match || q.match_phrase || [],
+            (q.conjuncts || []).map(queryText), (q.disjuncts || []).map(queryText)).join(" ");
+    }
+
+    function escapeHTML(text) {
+        return text.replace(/[&<>"]/g, function(c) {
+            return { "&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&quot;" }[c];
+        });
+    }
+
+    // answers the POST requests of jQuery to .json urls from the index
+    if (typeof jQuery !== "undefined") {
+        jQuery.ajaxTransport("+*", function(options) {
+            if (options.type !== "POST" || !/\.json(\?|#|$)/.test(options.url)) {
+                return;
+            }
+            return {
+                send: function(headers, complete) {
+                    load(options.url).then(function(index) {
+                        var result = index.search(JSON.parse(options.data));
+                        complete(200, "success", { text: JSON.stringify(result) }, "Content-Type: application/json");
+                    }).catch(function(err) {
+                        complete(500, String(err.message || err));
+                    });
+                },
+                abort: function() {}
+            };
+        });
+    }
+
+    return { load: load };
+})();
@@ -0,0 +1,245 @@
+package hugosearch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gohugoio/hugo/config"
+	"github.com/gohugoio/hugo/deps"
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/gohugoio/hugo/hugofs/files"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/parser/pageparser"
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/spf13/afero"
+	"github.com/spf13/cast"
+)
+
+var sourceFs afero.Fs = hugofs.Os
+
+// siteBuild is the content that hugo builds, drafts, future and expired pages are built when set
+type siteBuild struct {
+	drafts  bool
+	future  bool
+	expired bool
+	now     time.Time
+}
+
+// returns why hugo did not build the page of the front matter, with the default front matter
+// settings of the dates. Pages left out for another reason, like the drafts of a cascade, are not built.
+func (b siteBuild) exclusionReason(frontMatter map[string]interface{}) string {
+	if cast.ToBool(frontMatter["draft"]) && !b.drafts {
+		return "draft"
+	}
+	if date := frontMatterDate(frontMatter, "publishdate", "pubdate", "published", "date"); !date.IsZero() && date.After(b.now) && !b.future {
+		return "future"
+	}
+	if date := frontMatterDate(frontMatter, "expirydate", "unpublishdate"); !date.IsZero() && date.Before(b.now) && !b.expired {
+		return "expired"
+	}
+	return "not built"
+}
+
+// returns the first date of the front matter set by one of the keys
+func frontMatterDate(frontMatter map[string]interface{}, keys ...string) time.Time {
+	for _, key := range keys {
+		if date, err := cast.ToTimeE(frontMatter[key]); err == nil && !date.IsZero() {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// returns the pages of all languages of the site, regular pages and the home, section,
+// taxonomy and term pages of the kinds listed in the settings. The pages that hugo builds
+// but does not list are also returned, with the reason why they are excluded. The content
+// files that hugo does not build, like the drafts, are only logged.
+func (ix *Indexer) readSitePages() (page.Pages, map[page.Page]string, error) {
+
+	config, _, err := loadSiteConfig(ix.opts.SitePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the options only add to the site config, which can already build drafts
+	if ix.opts.BuildDrafts {
+		config.Set("buildDrafts", true)
+	}
+	if ix.opts.BuildFuture {
+		config.Set("buildFuture", true)
+	}
+	if ix.opts.BuildExpired {
+		config.Set("buildExpired", true)
+	}
+	build := siteBuild{
+		drafts:  config.GetBool("buildDrafts"),
+		future:  config.GetBool("buildFuture"),
+		expired: config.GetBool("buildExpired"),
+		now:     time.Now(),
+	}
+
+	fs := hugofs.NewFrom(sourceFs, config)
+
+	h, err := hugolib.NewHugoSites(deps.DepsCfg{Cfg: config, Fs: fs})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.Build(hugolib.BuildCfg{SkipRender: true}); err != nil {
+		return nil, nil, err
+	}
+
+	// all languages, use pagesByLanguage() to split them
+	var pages page.Pages
+	excluded := make(map[page.Page]string)
+	listed := make(map[page.Page]bool)
+	for _, site := range h.Sites {
+		for _, p := range site.Pages() {
+			listed[p] = true
+			if ix.opts.Config.indexedKind(p.Kind()) {
+				pages = append(pages, p)
+			}
+		}
+	}
+	for _, site := range h.Sites {
+		unlisted, unbuilt, err := contentPages(site, listed)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range unlisted {
+			if ix.opts.Config.indexedKind(p.Kind()) {
+				pages = append(pages, p)
+				excluded[p] = "not listed"
+			}
+		}
+		if ix.verbose {
+			for _, file := range unbuilt {
+				ix.log.Printf("Excluded: %s [%s] (%s)", file.path, cast.ToString(file.frontMatter["title"]), build.exclusionReason(file.frontMatter))
+			}
+		}
+	}
+	return pages, excluded, nil
+}
+
+// unbuiltFile is a content file of the site that hugo did not build
+type unbuiltFile struct {
+	path        string
+	frontMatter map[string]interface{}
+}
+
+// compares the content files of the site to the pages that hugo built. Returns the pages that
+// are not listed, like the pages with _build.list set to never and the headless bundles that
+// only GetPage finds, and the files without page, like the drafts, with their front matter.
+func contentPages(site *hugolib.Site, listed map[page.Page]bool) (page.Pages, []unbuiltFile, error) {
+	var pages page.Pages
+	var withoutPage []string
+	bundles := make(map[string]bool)
+	err := afero.Walk(site.BaseFs.Content.Fs, "", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !files.IsContentFile(path) {
+			return err
+		}
+		if fi, ok := info.(hugofs.FileMetaInfo); ok && fi.Meta().Lang != site.Language().Lang {
+			return nil
+		}
+		if isLeafBundle(path) {
+			bundles[filepath.Dir(path)] = true
+		}
+		p, err := site.Info.GetPage(path)
+		if err != nil || p == nil || p == page.NilPage {
+			withoutPage = append(withoutPage, path)
+			return nil
+		}
+		if !listed[p] {
+			listed[p] = true
+			pages = append(pages, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unbuilt []unbuiltFile
+	for _, path := range withoutPage {
+		// the other content files of a leaf bundle are its resources
+		if !isLeafBundle(path) && inBundle(path, bundles) {
+			continue
+		}
+		frontMatter, err := readFrontMatter(site.BaseFs.Content.Fs, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		unbuilt = append(unbuilt, unbuiltFile{path: path, frontMatter: frontMatter})
+	}
+	return pages, unbuilt, nil
+}
+
+// checks if the content file is the index of a leaf bundle
+func isLeafBundle(path string) bool {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name)) == "index"
+}
+
+// checks if the file is in one of the directories of the bundles or below
+func inBundle(path string, bundles map[string]bool) bool {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if bundles[dir] {
+			return true
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// returns the front matter of the content file with lowercase keys, like hugo reads them
+func readFrontMatter(fs afero.Fs, path string) (map[string]interface{}, error) {
+	file, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := pageparser.ParseFrontMatterAndContent(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	frontMatter := make(map[string]interface{})
+	for key, value := range content.FrontMatter {
+		frontMatter[strings.ToLower(key)] = value
+	}
+	return frontMatter, nil
+}
+
+// loads the configuration of the hugo site located at path, also returns the config files that were read
+func loadSiteConfig(path string) (config.Provider, []string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hugolib.LoadConfig(hugolib.ConfigSourceDescriptor{
+		Fs:         sourceFs,
+		Path:       dir,
+		WorkingDir: dir},
+	)
+}
+
+// groups the pages by their language
+func pagesByLanguage(pages page.Pages) map[string]page.Pages {
+	languages := make(map[string]page.Pages)
+	for _, p := range pages {
+		languages[p.Lang()] = append(languages[p.Lang()], p)
+	}
+	return languages
+}
+
+// checks if the page has a title, which is required to be displayed in the search result
+func (ix *Indexer) pageHasTitle(p page.Page) (foundTitle bool) {
+	foundTitle = len(p.Title()) > 0
+	if !foundTitle && ix.verbose {
+		ix.log.Println("WARN: Title is missing in file metadata:", p.Path())
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package hugosearch
+
+import (
+	"testing"
+
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+const testHugoPath = "../test"
+
+// reads the pages of the test site that hugo builds with the settings
+func readTestPages(t testing.TB, config Config) page.Pages {
+	pages, excluded, err := NewIndexer(testOptions(t, config)).readSitePages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var built page.Pages
+	for _, p := range pages {
+		if excluded[p] == "" {
+			built = append(built, p)
+		}
+	}
+	return built
+}
+
+// checks the number of pages built for the site (drafts don't count)
+func TestReadSitePages(t *testing.T) {
+	pages := readTestPages(t, Config{})
+	actual := pages.Len()
+	expected := 5
+
+	if expected != actual {
+		var titles []string
+		for _, page := range pages {
+			titles = append(titles, "'"+page.Title()+":"+page.Kind()+"'")
+		}
+		t.Errorf("Expected: %d, was: %d, pages returned:\n%s", expected, actual, titles)
+	}
+}
+
+// checks that pages with no title are correctly detected
+func TestPageHasTitle(t *testing.T) {
+	ix := NewIndexer(testOptions(t, Config{}))
+	pages := readTestPages(t, Config{})
+	var a, b bool
+	for _, page := range pages {
+		if page.Title() == "Title-page-1" {
+			a = ix.pageHasTitle(page)
+		} else if page.Title() == "" {
+			b = !ix.pageHasTitle(page)
+		}
+	}
+	if !(a && b) {
+		t.Errorf("Expected: has title==(true && false), was: (%v && %v)", a, b)
+	}
+}
+
+// checks that the list pages of the kinds in the settings are read
+func TestReadSitePagesKinds(t *testing.T) {
+	kinds := make(map[string]string)
+	for _, page := range readTestPages(t, Config{Kinds: []string{"page", "section", "term"}}) {
+		kinds[page.RelPermalink()] = page.Kind()
+	}
+	for link, kind := range map[string]string{"/page1/": "page", "/parent1/": "section", "/tags/tag1/": "term"} {
+		if kinds[link] != kind {
+			t.Errorf("Expected %s of kind %s, pages returned:\n%v", link, kind, kinds)
+		}
+	}
+	if _, found := kinds["/"]; found {
+		t.Errorf("Expected no home page, pages returned:\n%v", kinds)
+	}
+}
@@ -0,0 +1,63 @@
+// Package hugosearch builds bleve search indexes from the pages of a hugo site and serves them
+// over http. The Indexer builds the index of the site, the Server answers the search API.
+package hugosearch
+
+import "log"
+
+// Options of the Indexer and the Server
+type Options struct {
+	// path of the hugo site, the Server rebuilds the index from it when reloaded unless empty
+	SitePath string
+
+	// directory of the generations of the bleve index, multilingual sites get one index per language in each
+	IndexPath string
+
+	// bleve index mapping file (JSON, YAML or TOML), the mapping of hugo-search when empty
+	MappingFile string
+
+	// file of the best bets rules (JSON, YAML or TOML) applied by the Server, read again when modified
+	RulesFile string
+
+	// http listen address of the Server
+	Addr string
+
+	// also serve all languages of a multilingual site through a single index
+	Combined bool
+
+	// content that hugo does not build by default, in addition to the site config
+	BuildDrafts  bool
+	BuildFuture  bool
+	BuildExpired bool
+
+	// number of previous indexes kept for a rollback when the index is rebuilt
+	Generations int
+
+	// documents written to the index at once, 500 when 0
+	BatchSize int
+
+	// pages converted to their index entries in parallel, the number of CPUs when 0
+	Workers int
+
+	// settings of the search, usually read from the site config with ReadConfig
+	Config Config
+
+	// logger of the messages, the standard logger when nil
+	Logger *log.Logger
+
+	// also log the pages indexed, excluded and deleted and the errors reported to clients
+	Verbose bool
+}
+
+// logging of the indexer, the server and its handlers
+type logging struct {
+	log     *log.Logger
+	verbose bool
+}
+
+func newLogging(opts Options) logging {
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	return logging{log: logger, verbose: opts.Verbose}
+}
@@ -0,0 +1,517 @@
+package hugosearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/index/scorch"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// prefix of the internal keys that hold the state of each indexed page
+const pageStatePrefix = "page:"
+
+// internal key of the buildInfo of the index
+const buildInfoKey = "build"
+
+// number of documents written to the index at once by default
+const defaultBatchSize = 500
+
+// pageState is stored next to each document so that the next build
+// can tell whether the page needs to be re-indexed
+type pageState struct {
+	Hash    string    `json:"hash"`
+	Lastmod time.Time `json:"lastmod"`
+}
+
+// buildInfo is stored in the index when it is built, for the metrics of the server
+type buildInfo struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+}
+
+// returns the build info of the index, nil for indexes built before it was stored
+func readBuildInfo(index bleve.Index) (*buildInfo, error) {
+	data, err := index.GetInternal([]byte(buildInfoKey))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var info buildInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// stores the end and the duration of the build started at start in the index
+func writeBuildInfo(index bleve.Index, start time.Time) error {
+	now := time.Now()
+	data, err := json.Marshal(buildInfo{Time: now.UTC(), Duration: now.Sub(start)})
+	if err != nil {
+		return err
+	}
+	return index.SetInternal([]byte(buildInfoKey), data)
+}
+
+// Indexer builds the search index of a hugo site
+type Indexer struct {
+	logging
+	opts Options
+}
+
+// NewIndexer returns the indexer of the site at the site path of the options
+func NewIndexer(opts Options) *Indexer {
+	return &Indexer{logging: newLogging(opts), opts: opts}
+}
+
+// Build builds the index at the index path of the options from the pages of the site that are not
+// excluded, multilingual sites get one index per language. The index is updated incrementally: pages
+// that did not change since the last build are kept as they are. A build cancelled by the context can
+// be completed by the next one.
+func (ix *Indexer) Build(ctx context.Context) error {
+	if _, err := ix.buildGeneration(ctx, ix.opts.IndexPath); err != nil {
+		return err
+	}
+	return ix.removeOldGenerations(ix.opts.IndexPath)
+}
+
+// builds the search index by passing the pages of hugo site that are not excluded to the indexer,
+// multilingual sites get one index per language. The indexes are built in a new generation at
+// indexPath that becomes the current one when they are all valid, returns the path of its index.
+// An error leaves the current generation as it was.
+func (ix *Indexer) buildGeneration(ctx context.Context, indexPath string) (string, error) {
+	languages, err := ix.readLanguages()
+	if err != nil {
+		return "", err
+	}
+	current, err := CurrentIndexPath(indexPath)
+	if err != nil {
+		return "", err
+	}
+	buildDir, err := newBuildDir(indexPath)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(buildDir)
+
+	// the languages removed from the site are left out, they are kept in the previous generations
+	names := languagePaths(indexPath, languages)
+	previousPaths := languagePaths(current, languages)
+	paths := languagePaths(filepath.Join(buildDir, filepath.Base(indexPath)), languages)
+	for lang, pages := range languages {
+		progress := ix.newProgress(names[lang], len(pages))
+		if err := ix.buildIndex(ctx, paths[lang], previousPaths[lang], lang, pages, progress); err != nil {
+			return "", err
+		}
+	}
+	return publishGeneration(indexPath, buildDir)
+}
+
+// returns the pages of the site that are indexed by language, a site without pages has an empty language
+func (ix *Indexer) readLanguages() (map[string]page.Pages, error) {
+	pages, excluded, err := ix.readSitePages()
+	if err != nil {
+		return nil, err
+	}
+	pages, err = ix.indexedPages(pages, excluded)
+	if err != nil {
+		return nil, err
+	}
+	languages := pagesByLanguage(pages)
+	if len(languages) == 0 {
+		// a site without pages still gets an empty index
+		languages[""] = nil
+	}
+	return languages, nil
+}
+
+// returns the paths of the indexes of the languages, named after path when there are several
+func languagePaths(path string, languages map[string]page.Pages) map[string]string {
+	paths := make(map[string]string)
+	for lang := range languages {
+		if len(languages) > 1 {
+			paths[lang] = LanguageIndexPath(path, lang)
+		} else {
+			paths[lang] = path
+		}
+	}
+	return paths
+}
+
+// builds the index of a language at buildPath from a copy of its previous index, so that it is updated
+// incrementally without modifying the index that is served
+func (ix *Indexer) buildIndex(ctx context.Context, buildPath string, previousPath string, lang string, pages page.Pages, progress *progress) error {
+	indexMapping, err := ix.newIndexMapping(lang)
+	if err != nil {
+		return err
+	}
+	if isIndex(previousPath) {
+		if err := copyIndex(previousPath, buildPath); err != nil {
+			return err
+		}
+	}
+	return ix.writeIndex(ctx, buildPath, indexMapping, pages, progress)
+}
+
+// writes the pages to the index at path and validates it, pages that did not change since the last
+// build are kept as they are. The pages are converted to their entries by the workers and written to
+// the index in batches.
+func (ix *Indexer) writeIndex(ctx context.Context, path string, indexMapping mapping.IndexMapping, pages page.Pages, progress *progress) error {
+	index, err := ix.openOrCreateIndex(path, indexMapping)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	links := make(map[string]bool)
+	batch := index.NewBatch()
+	for converted := range ix.convertPages(ctx, index, pages) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if converted.err != nil {
+			return converted.err
+		}
+		if err := ix.addPageToBatch(batch, converted); err != nil {
+			return err
+		}
+		if batch.Size() >= ix.batchSize() {
+			if err := index.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		for _, id := range converted.ids {
+			links[id] = true
+		}
+		progress.add(time.Now())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := index.Batch(batch); err != nil {
+		return err
+	}
+	progress.finish(time.Now())
+	if err := ix.removeDeletedPages(index, links); err != nil {
+		return err
+	}
+	if err := compactIndex(ctx, index); err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(links))
+	for id := range links {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if err := validateIndex(index, ids); err != nil {
+		return err
+	}
+	return writeBuildInfo(index, progress.start)
+}
+
+// returns the number of documents written to the index at once
+func (ix *Indexer) batchSize() int {
+	if ix.opts.BatchSize > 0 {
+		return ix.opts.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// returns the number of pages converted to their entries in parallel
+func (ix *Indexer) workers() int {
+	if ix.opts.Workers > 0 {
+		return ix.opts.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// opens the index at path, or creates it if there is none yet or if its mapping changed
+func (ix *Indexer) openOrCreateIndex(path string, indexMapping mapping.IndexMapping) (bleve.Index, error) {
+	index, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		return ix.createIndex(path, indexMapping)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sameMapping(index.Mapping(), indexMapping) {
+		index.Close()
+		if ix.verbose {
+			ix.log.Println("Mapping changed:", path)
+		}
+		return ix.createIndex(path, indexMapping)
+	}
+	if !isScorchIndex(index) {
+		index.Close()
+		if ix.verbose {
+			ix.log.Println("Index format changed:", path)
+		}
+		return ix.createIndex(path, indexMapping)
+	}
+	if ix.verbose {
+		ix.log.Println("Updating Index:", path)
+	}
+	return index, nil
+}
+
+// compares two index mappings by their JSON representation, mappings that cannot be compared differ
+func sameMapping(a mapping.IndexMapping, b mapping.IndexMapping) bool {
+	dataA, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	dataB, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(dataA, dataB)
+}
+
+// checks if the index is stored by scorch, which writes batches faster than the format of the previous versions
+func isScorchIndex(index bleve.Index) bool {
+	i, _, err := index.Advanced()
+	if err != nil {
+		return false
+	}
+	_, ok := i.(*scorch.Scorch)
+	return ok
+}
+
+// creates the index from scratch (does not reuse existing index)
+func (ix *Indexer) createIndex(path string, indexMapping mapping.IndexMapping) (bleve.Index, error) {
+	if ix.verbose {
+		ix.log.Println("Creating Index:", path)
+	}
+
+	// index_meta.go, line 59: os.Mkdir(path, 0700) fails if parent directory missing
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	// remove leftovers of an index that could not be opened
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	return bleve.NewUsing(path, indexMapping, scorch.Name, scorch.Name, nil)
+}
+
+// convertedPage is a page converted to the entries of its documents, with its state when it changed
+// since the last build
+type convertedPage struct {
+	page    page.Page
+	ids     []string
+	entries []*PageEntry
+	state   *pageState
+	err     error
+}
+
+// converts the pages to their entries with the workers, the results come in any order until the
+// pages are converted or the context is done
+func (ix *Indexer) convertPages(ctx context.Context, index bleve.Index, pages page.Pages) <-chan *convertedPage {
+	jobs := make(chan page.Page)
+	go func() {
+		defer close(jobs)
+		for _, p := range pages {
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan *convertedPage)
+	var wg sync.WaitGroup
+	for i := 0; i < ix.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				select {
+				case results <- ix.convertPage(index, p):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// converts a hugo page to the entries of its documents, which are more than one when the page is
+// split at its headings. The state is nil when the page did not change since the last build.
+func (ix *Indexer) convertPage(index bleve.Index, p page.Page) *convertedPage {
+	ids, entries, err := ix.pageEntries(p)
+	if err != nil {
+		return &convertedPage{err: err}
+	}
+	state, err := newPageState(entries)
+	if err != nil {
+		return &convertedPage{err: err}
+	}
+	changed, err := pageChanged(index, p.RelPermalink(), state)
+	if err != nil {
+		return &convertedPage{err: err}
+	}
+	if !changed {
+		state = nil
+	}
+	return &convertedPage{page: p, ids: ids, entries: entries, state: state}
+}
+
+// adds the documents of a converted page and its state to the batch, unless it did not change since the last build
+func (ix *Indexer) addPageToBatch(batch *bleve.Batch, converted *convertedPage) error {
+	p := converted.page
+	if converted.state == nil {
+		if ix.verbose {
+			ix.log.Printf("Unchanged: %s [%s]", p.Path(), p.Title())
+		}
+		return nil
+	}
+	for i, id := range converted.ids {
+		if err := batch.Index(id, converted.entries[i]); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(converted.state)
+	if err != nil {
+		return err
+	}
+	batch.SetInternal([]byte(pageStatePrefix+p.RelPermalink()), data)
+	if ix.verbose {
+		ix.log.Printf("Indexed: %s [%s]", p.Path(), p.Title())
+	}
+	return nil
+}
+
+// returns the entries of the page and their identifiers, the page comes first and then its sections
+// when the pages are split at their headings
+func (ix *Indexer) pageEntries(p page.Page) ([]string, []*PageEntry, error) {
+	ids := []string{p.RelPermalink()}
+	entries := []*PageEntry{ix.newIndexEntry(p)}
+	if ix.opts.Config.SplitHeadings {
+		sectionIDs, sectionEntries, err := newSectionEntries(p, entries[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, sectionIDs...)
+		entries = append(entries, sectionEntries...)
+	}
+	return ids, entries, nil
+}
+
+// computes the state of the index entries of a page, the hash covers every indexed field
+func newPageState(entries []*PageEntry) (*pageState, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &pageState{
+		Hash:    hex.EncodeToString(sum[:]),
+		Lastmod: entries[0].LastModified,
+	}, nil
+}
+
+// checks the state of the page against the one stored by the previous build
+func pageChanged(index bleve.Index, link string, state *pageState) (bool, error) {
+	data, err := index.GetInternal([]byte(pageStatePrefix + link))
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		return true, nil
+	}
+	var previous pageState
+	if json.Unmarshal(data, &previous) != nil {
+		return true, nil
+	}
+	return previous.Hash != state.Hash || !previous.Lastmod.Equal(state.Lastmod), nil
+}
+
+// deletes the documents whose page or section does not exist anymore (otherwise search returns deleted pages)
+func (ix *Indexer) removeDeletedPages(index bleve.Index, links map[string]bool) error {
+	ids, err := indexedDocIDs(index)
+	if err != nil {
+		return err
+	}
+	batch := index.NewBatch()
+	for _, id := range ids {
+		if links[id] {
+			continue
+		}
+		batch.Delete(id)
+		batch.DeleteInternal([]byte(pageStatePrefix + id))
+		if ix.verbose {
+			ix.log.Println("Deleted:", id)
+		}
+	}
+	return index.Batch(batch)
+}
+
+// merges the segments written by the batches of a scorch index, which removes the deleted documents
+// from the counts of the terms and speeds up the search
+func compactIndex(ctx context.Context, index bleve.Index) error {
+	i, _, err := index.Advanced()
+	if err != nil {
+		return err
+	}
+	if s, ok := i.(*scorch.Scorch); ok {
+		return s.ForceMerge(ctx, nil)
+	}
+	return nil
+}
+
+// returns the identifiers of all documents in the index
+func indexedDocIDs(index bleve.Index) ([]string, error) {
+	i, _, err := index.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	docs, err := reader.DocIDReaderAll()
+	if err != nil {
+		return nil, err
+	}
+	defer docs.Close()
+
+	var ids []string
+	for {
+		internal, err := docs.Next()
+		if err != nil {
+			return nil, err
+		}
+		if internal == nil {
+			return ids, nil
+		}
+		id, err := reader.ExternalID(internal)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+}
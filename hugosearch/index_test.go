@@ -0,0 +1,335 @@
+package hugosearch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// returns the options of the test site, its index is built in a temporary directory of the test
+func testOptions(t testing.TB, config Config) Options {
+	return Options{SitePath: testHugoPath, IndexPath: filepath.Join(t.TempDir(), "search.bleve"), Config: config}
+}
+
+// builds the index with the options
+func buildTestIndex(t testing.TB, opts Options) {
+	if err := NewIndexer(opts).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checks the actual index creation and validity
+func TestBuildIndex(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+	index := openIndex(t, opts.IndexPath)
+	defer index.Close()
+	queryIndex(t, index)
+}
+
+// loads the index of the current generation at path
+func openIndex(t *testing.T, path string) bleve.Index {
+	path, err := CurrentIndexPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := bleve.OpenUsing(path, map[string]interface{}{"read_only": true})
+	if err != nil {
+		t.Errorf("error opening index %s: %v", path, err)
+	} else if index == nil {
+		t.Error("null index")
+	}
+	return index
+}
+
+// queries the index
+func queryIndex(t *testing.T, index bleve.Index) {
+	query := bleve.NewTermQuery("lorem")
+	request := bleve.NewSearchRequest(query)
+	result, err := index.Search(request)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Total < 1 {
+		t.Error("No hits for 'lorem', expected at least one.")
+	}
+}
+
+// checks that an existing index is updated and that documents of deleted pages are removed
+func TestIncrementalIndex(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+
+	current, err := CurrentIndexPath(opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := bleve.Open(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := index.DocCount()
+	if err = index.Index("/deleted/", &PageEntry{Title: "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	buildTestIndex(t, opts)
+	index = openIndex(t, opts.IndexPath)
+	defer index.Close()
+
+	if doc, _ := index.Document("/deleted/"); doc != nil {
+		t.Error("Document of deleted page still in index")
+	}
+	if actual, _ := index.DocCount(); actual != expected {
+		t.Errorf("Expected: %d documents, was: %d", expected, actual)
+	}
+}
+
+// returns the stored state and content of the page at link
+func storedPage(t *testing.T, indexPath string, link string) (string, string) {
+	index := openIndex(t, indexPath)
+	defer index.Close()
+
+	state, err := index.GetInternal([]byte(pageStatePrefix + link))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{link}))
+	request.Fields = []string{"content"}
+	result, err := index.Search(request)
+	if err != nil || len(result.Hits) != 1 {
+		t.Fatalf("Document %s not found: %v", link, err)
+	}
+	return string(state), stringField(result.Hits[0].Fields, "content")
+}
+
+// checks that unchanged pages are skipped by the next build, and that pages are indexed again
+// when their content or their modification date changed
+func TestIncrementalIndexChanges(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.SitePath = filepath.Join(t.TempDir(), "site")
+	if err := copyDir(testHugoPath, opts.SitePath); err != nil {
+		t.Fatal(err)
+	}
+	var logs strings.Builder
+	opts.Logger, opts.Verbose = log.New(&logs, "", 0), true
+	build := func() {
+		logs.Reset()
+		buildTestIndex(t, opts)
+	}
+	writePage := func(name string, old string, new string) {
+		path := filepath.Join(opts.SitePath, "content", name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build()
+	state1, content1 := storedPage(t, opts.IndexPath, "/page1/")
+	state2, content2 := storedPage(t, opts.IndexPath, "/page2/")
+
+	// nothing changed
+	build()
+	if !strings.Contains(logs.String(), "Unchanged: page1.md") || strings.Contains(logs.String(), "Indexed: page1.md") {
+		t.Errorf("Expected page1.md to be skipped, was:\n%s", logs.String())
+	}
+	if state, content := storedPage(t, opts.IndexPath, "/page1/"); state != state1 || content != content1 {
+		t.Errorf("Expected unchanged page, was: %s %q", state, content)
+	}
+
+	// content of page 2 changed
+	writePage("page2.md", "consectetur", "changed")
+	build()
+	if !strings.Contains(logs.String(), "Indexed: page2.md") || !strings.Contains(logs.String(), "Unchanged: page1.md") {
+		t.Errorf("Expected only page2.md to be indexed, was:\n%s", logs.String())
+	}
+	if state, content := storedPage(t, opts.IndexPath, "/page2/"); state == state2 || content == content2 || !strings.Contains(content, "changed") {
+		t.Errorf("Expected changed page, was: %s %q", state, content)
+	}
+
+	// modification date of page 1 changed
+	writePage("page1.md", "draft: false", "draft: false\nlastmod: \"2020-01-01T00:00:00Z\"")
+	build()
+	if !strings.Contains(logs.String(), "Indexed: page1.md") {
+		t.Errorf("Expected page1.md to be indexed, was:\n%s", logs.String())
+	}
+	state, content := storedPage(t, opts.IndexPath, "/page1/")
+	if state == state1 || !strings.Contains(state, "2020-01-01") || content != content1 {
+		t.Errorf("Expected new modification date, was: %s %q", state, content)
+	}
+}
+
+// checks that a cancelled build reports the error of the context
+func TestBuildCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewIndexer(testOptions(t, Config{})).Build(ctx); err != context.Canceled {
+		t.Errorf("Expected: %v, was: %v", context.Canceled, err)
+	}
+}
+
+// checks that the documents are the same whatever the batch size and the number of workers
+func TestBuildBatches(t *testing.T) {
+	opts := testOptions(t, Config{SplitHeadings: true})
+	buildTestIndex(t, opts)
+	index := openIndex(t, opts.IndexPath)
+	expected, _ := index.DocCount()
+	index.Close()
+
+	opts = testOptions(t, Config{SplitHeadings: true})
+	opts.BatchSize, opts.Workers = 1, 3
+	buildTestIndex(t, opts)
+	index = openIndex(t, opts.IndexPath)
+	defer index.Close()
+
+	if actual, _ := index.DocCount(); actual != expected {
+		t.Errorf("Expected: %d documents, was: %d", expected, actual)
+	}
+	queryIndex(t, index)
+}
+
+// checks that an index in the format of the previous versions is created again with scorch
+func TestBuildIndexFormatChanged(t *testing.T) {
+	opts := testOptions(t, Config{})
+	ix := NewIndexer(opts)
+	indexMapping, err := ix.newIndexMapping("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := bleve.New(opts.IndexPath, indexMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	buildTestIndex(t, opts)
+	index = openIndex(t, opts.IndexPath)
+	defer index.Close()
+	if !isScorchIndex(index) {
+		t.Errorf("Expected scorch index")
+	}
+	queryIndex(t, index)
+}
+
+// number of pages of the generated site of the benchmarks
+const benchmarkPages = 20000
+
+// words of the content of the generated pages
+var benchmarkWords = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod " +
+	"tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation " +
+	"ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate")
+
+// writes a site of generated pages with tags and headings to dir, published over 20 years: hugo relates
+// the pages of the same year, which takes longer than indexing them when they are all of the same year
+func generateSite(b *testing.B, dir string, pages int) {
+	config := "baseURL = \"http://localhost/\"\ntitle = \"generated\"\n[taxonomies]\n  tag = \"tags\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(config), 0644); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < pages; i++ {
+		var content strings.Builder
+		fmt.Fprintf(&content, "---\ntitle: Page %d\ndate: %d-%02d-%02d\ntags: [tag%d, tag%d]\n---\n", i, 2002+i%20, 1+i%12, 1+i%28, i%10, i%7)
+		for section := 0; section < 3; section++ {
+			fmt.Fprintf(&content, "\n## Heading %d\n\n", section)
+			for w := 0; w < 200; w++ {
+				content.WriteString(benchmarkWords[(i*7+section*13+w*w)%len(benchmarkWords)] + " ")
+			}
+			content.WriteString("\n")
+		}
+		path := filepath.Join(dir, "content", fmt.Sprintf("section%d", i%20), fmt.Sprintf("page%d.md", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content.String()), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// returns the indexer of the generated site and its pages by language, the site is only read once
+func benchmarkIndexer(b *testing.B, batchSize int, workers int) (*Indexer, map[string]page.Pages) {
+	if testing.Short() {
+		b.Skip("skipping the build of the generated site in short mode")
+	}
+	dir := b.TempDir()
+	generateSite(b, dir, benchmarkPages)
+	opts := Options{SitePath: dir, IndexPath: filepath.Join(dir, "search.bleve"), BatchSize: batchSize, Workers: workers}
+	opts.Config.SplitHeadings = true
+	ix := NewIndexer(opts)
+	languages, err := ix.readLanguages()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ix, languages
+}
+
+// builds the index of the generated site from scratch with the batch size and the number of workers
+func benchmarkBuild(b *testing.B, batchSize int, workers int) {
+	ix, languages := benchmarkIndexer(b, batchSize, workers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		os.RemoveAll(ix.opts.IndexPath)
+		b.StartTimer()
+		for lang, pages := range languages {
+			progress := ix.newProgress(ix.opts.IndexPath, len(pages))
+			if err := ix.buildIndex(context.Background(), ix.opts.IndexPath, "", lang, pages, progress); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// one document per commit with index.Index, as before batches
+func BenchmarkBuildPerPage(b *testing.B) {
+	ix, languages := benchmarkIndexer(b, 1, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		os.RemoveAll(ix.opts.IndexPath)
+		b.StartTimer()
+		for lang, pages := range languages {
+			indexMapping, err := ix.newIndexMapping(lang)
+			if err != nil {
+				b.Fatal(err)
+			}
+			index, err := ix.openOrCreateIndex(ix.opts.IndexPath, indexMapping)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, p := range pages {
+				ids, entries, err := ix.pageEntries(p)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for j, entry := range entries {
+					if err := index.Index(ids[j], entry); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			index.Close()
+		}
+	}
+}
+
+func BenchmarkBuildBatch(b *testing.B) {
+	benchmarkBuild(b, 0, 1)
+}
+
+func BenchmarkBuildBatchWorkers(b *testing.B) {
+	benchmarkBuild(b, 0, 0)
+}
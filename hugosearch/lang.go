@@ -0,0 +1,80 @@
+package hugosearch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/registry"
+
+	// analyzers of the languages supported by bleve, registered by language code
+	_ "github.com/blevesearch/bleve/analysis/lang/ar"
+	_ "github.com/blevesearch/bleve/analysis/lang/ckb"
+	_ "github.com/blevesearch/bleve/analysis/lang/da"
+	_ "github.com/blevesearch/bleve/analysis/lang/de"
+	_ "github.com/blevesearch/bleve/analysis/lang/en"
+	_ "github.com/blevesearch/bleve/analysis/lang/es"
+	_ "github.com/blevesearch/bleve/analysis/lang/fa"
+	_ "github.com/blevesearch/bleve/analysis/lang/fi"
+	_ "github.com/blevesearch/bleve/analysis/lang/fr"
+	_ "github.com/blevesearch/bleve/analysis/lang/hi"
+	_ "github.com/blevesearch/bleve/analysis/lang/hu"
+	_ "github.com/blevesearch/bleve/analysis/lang/it"
+	_ "github.com/blevesearch/bleve/analysis/lang/nl"
+	_ "github.com/blevesearch/bleve/analysis/lang/no"
+	_ "github.com/blevesearch/bleve/analysis/lang/pt"
+	_ "github.com/blevesearch/bleve/analysis/lang/ro"
+	_ "github.com/blevesearch/bleve/analysis/lang/ru"
+	_ "github.com/blevesearch/bleve/analysis/lang/sv"
+	_ "github.com/blevesearch/bleve/analysis/lang/tr"
+)
+
+// returns the name of the bleve analyzer for a hugo language code, e.g. "de" for "de-ch"
+func languageAnalyzer(lang string) string {
+	code := strings.ToLower(lang)
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	switch code {
+	case "ja", "ko", "zh":
+		return cjk.AnalyzerName
+	}
+	if _, err := registry.NewCache().AnalyzerNamed(code); err == nil {
+		return code
+	}
+	return standard.Name
+}
+
+// LanguageIndexPath returns the path of the index of a language of a multilingual site, e.g. indexes/search.de.bleve
+func LanguageIndexPath(indexPath string, lang string) string {
+	ext := filepath.Ext(indexPath)
+	return strings.TrimSuffix(indexPath, ext) + "." + lang + ext
+}
+
+// returns the paths of the language indexes found next to indexPath, by language
+func findLanguageIndexes(indexPath string) (map[string]string, error) {
+	ext := filepath.Ext(indexPath)
+	prefix := strings.TrimSuffix(indexPath, ext) + "."
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]string)
+	for _, match := range matches {
+		lang := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if strings.Contains(lang, ".") || !isIndex(match) {
+			continue
+		}
+		indexes[lang] = match
+	}
+	return indexes, nil
+}
+
+// checks if there is a bleve index at path
+func isIndex(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "index_meta.json"))
+	return err == nil
+}
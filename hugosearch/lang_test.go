@@ -0,0 +1,90 @@
+package hugosearch
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	bleveHttp "github.com/blevesearch/bleve/http"
+)
+
+const testMultilingualHugoPath = "../test/multilingual"
+
+// returns the options of the multilingual test site, its indexes are built in a temporary directory of the test
+func testMultilingualOptions(t testing.TB) Options {
+	return Options{SitePath: testMultilingualHugoPath, IndexPath: filepath.Join(t.TempDir(), "search.bleve"), Combined: true}
+}
+
+// checks the analyzer chosen for hugo language codes
+func TestLanguageAnalyzer(t *testing.T) {
+	for lang, expected := range map[string]string{"de-ch": "de", "fr": "fr", "zh-tw": "cjk", "xx": "standard"} {
+		if actual := languageAnalyzer(lang); actual != expected {
+			t.Errorf("%s: expected: %q, was: %q", lang, expected, actual)
+		}
+	}
+}
+
+// checks that each language gets its own index and that translations are linked
+func TestBuildMultilingualIndex(t *testing.T) {
+	opts := testMultilingualOptions(t)
+	buildTestIndex(t, opts)
+	current, err := CurrentIndexPath(opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := findLanguageIndexes(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var langs []string
+	for lang := range indexes {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	if expected := []string{"de", "en", "fr"}; !reflect.DeepEqual(expected, langs) {
+		t.Fatalf("Expected: %v, was: %v", expected, langs)
+	}
+
+	index := openIndex(t, LanguageIndexPath(current, "de"))
+	defer index.Close()
+
+	// the german analyzer stems "Häuser" to "haus"
+	request := bleve.NewSearchRequest(bleve.NewMatchQuery("Haus"))
+	request.Fields = []string{"lang", "translations.fr"}
+	result, err := index.Search(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 {
+		t.Fatalf("Expected: 1 hit, was: %d", result.Total)
+	}
+	hit := result.Hits[0]
+	if hit.Fields["lang"] != "de" || hit.Fields["translations.fr"] != "/fr/page1/" {
+		t.Errorf("Unexpected fields: %v", hit.Fields)
+	}
+}
+
+// checks that the languages and the combined alias are registered
+func TestRegisterIndexes(t *testing.T) {
+	opts := testMultilingualOptions(t)
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	expected := []string{"de", "en", "fr", "search.bleve"}
+	if !reflect.DeepEqual(expected, server.indexNames) {
+		t.Errorf("Expected: %v, was: %v", expected, server.indexNames)
+	}
+
+	// the combined index lists the fields of the languages
+	fields, err := bleveHttp.IndexByName("search.bleve").Fields()
+	if err != nil || !containsString(fields, "translations.de") {
+		t.Errorf("Expected field translations.de, was: %v (%v)", fields, err)
+	}
+}
@@ -0,0 +1,380 @@
+package hugosearch
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis/lang/en"
+)
+
+// version of lunr.js whose serialized index is written, lunr.Index.load only warns about other 2.x versions
+const lunrVersion = "2.3.9"
+
+// parameters of the BM25 scoring of lunr's builder
+const (
+	lunrK1 = 1.2
+	lunrB  = 0.75
+)
+
+// lunrIndex is the serialized form of lunr.Index (see lunr.Index.prototype.toJSON)
+type lunrIndex struct {
+	Version       string          `json:"version"`
+	Fields        []string        `json:"fields"`
+	FieldVectors  [][]interface{} `json:"fieldVectors"`
+	InvertedIndex [][]interface{} `json:"invertedIndex"`
+	Pipeline      []string        `json:"pipeline"`
+}
+
+// lunrBuilder builds an index like lunr.Builder with the default pipeline of lunr(): trimmer,
+// stop word filter and stemmer. Terms are the same as lunr's so that lunr's query parser finds them.
+// The stop words and the stemmer are english, the words of other languages are only trimmed.
+type lunrBuilder struct {
+	fields   []string
+	boosts   map[string]float64
+	english  bool
+	docCount int
+
+	// term index and postings (field, ref) of each term, in order of insertion
+	terms    map[string]int
+	postings []map[string]map[string]bool
+
+	// term frequencies and length of each field of each document, by field ref ("field/ref")
+	fieldRefs   []string
+	frequencies map[string]map[string]int
+	lengths     map[string]int
+}
+
+func newLunrBuilder(fields []string, boosts map[string]float64, lang string) *lunrBuilder {
+	return &lunrBuilder{
+		fields:      fields,
+		boosts:      boosts,
+		english:     isEnglish(lang),
+		terms:       make(map[string]int),
+		frequencies: make(map[string]map[string]int),
+		lengths:     make(map[string]int),
+	}
+}
+
+// adds a document, the values are strings (split into tokens) or lists of strings (one token each)
+func (b *lunrBuilder) add(ref string, values map[string]interface{}) {
+	b.docCount++
+	for _, field := range b.fields {
+		var tokens []string
+		switch value := values[field].(type) {
+		case string:
+			tokens = lunrTokenize(value)
+		case []string:
+			for _, token := range value {
+				tokens = append(tokens, strings.ToLower(token))
+			}
+		}
+		terms := lunrTrim(tokens)
+		if b.english {
+			terms = lunrPipeline(tokens)
+		}
+
+		fieldRef := field + "/" + ref
+		frequencies := make(map[string]int)
+		b.fieldRefs = append(b.fieldRefs, fieldRef)
+		b.frequencies[fieldRef] = frequencies
+		b.lengths[fieldRef] = len(terms)
+		for _, term := range terms {
+			frequencies[term]++
+			index, ok := b.terms[term]
+			if !ok {
+				index = len(b.postings)
+				b.terms[term] = index
+				b.postings = append(b.postings, make(map[string]map[string]bool))
+			}
+			posting := b.postings[index]
+			if posting[field] == nil {
+				posting[field] = make(map[string]bool)
+			}
+			posting[field][ref] = true
+		}
+	}
+}
+
+// returns the serialized index
+func (b *lunrBuilder) build() *lunrIndex {
+	averageLengths := make(map[string]float64)
+	for _, fieldRef := range b.fieldRefs {
+		averageLengths[lunrFieldName(fieldRef)] += float64(b.lengths[fieldRef])
+	}
+	for field := range averageLengths {
+		averageLengths[field] /= float64(b.docCount)
+	}
+
+	// the search pipeline of lunr.Index.load runs the query terms through the same stemmer
+	pipeline := []string{}
+	if b.english {
+		pipeline = append(pipeline, "stemmer")
+	}
+	index := &lunrIndex{Version: lunrVersion, Fields: b.fields, Pipeline: pipeline}
+	for _, fieldRef := range b.fieldRefs {
+		field := lunrFieldName(fieldRef)
+		boost := b.boosts[field]
+		if boost == 0 {
+			boost = 1
+		}
+
+		// the vector is sorted by term index, with scores rounded to 3 decimals like lunr's
+		frequencies := b.frequencies[fieldRef]
+		terms := make([]string, 0, len(frequencies))
+		for term := range frequencies {
+			terms = append(terms, term)
+		}
+		sort.Slice(terms, func(i, j int) bool { return b.terms[terms[i]] < b.terms[terms[j]] })
+		vector := make([]interface{}, 0, 2*len(terms))
+		for _, term := range terms {
+			tf := float64(frequencies[term])
+			norm := 1 - lunrB + lunrB*float64(b.lengths[fieldRef])/averageLengths[field]
+			score := b.idf(term) * (lunrK1 + 1) * tf / (lunrK1*norm + tf) * boost
+			vector = append(vector, b.terms[term], math.Floor(score*1000+0.5)/1000)
+		}
+		index.FieldVectors = append(index.FieldVectors, []interface{}{fieldRef, vector})
+	}
+
+	// lunr.Index.load builds its token set from the terms, which must be sorted like javascript strings
+	terms := make([]string, 0, len(b.terms))
+	for term := range b.terms {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool { return lessUTF16(terms[i], terms[j]) })
+	for _, term := range terms {
+		posting := map[string]interface{}{"_index": b.terms[term]}
+		for _, field := range b.fields {
+			refs := make(map[string]struct{})
+			for ref := range b.postings[b.terms[term]][field] {
+				refs[ref] = struct{}{}
+			}
+			posting[field] = refs
+		}
+		index.InvertedIndex = append(index.InvertedIndex, []interface{}{term, posting})
+	}
+	return index
+}
+
+// returns the inverse document frequency of the term like lunr.idf
+func (b *lunrBuilder) idf(term string) float64 {
+	withTerm := 0
+	for _, refs := range b.postings[b.terms[term]] {
+		withTerm += len(refs)
+	}
+	x := (float64(b.docCount-withTerm) + 0.5) / (float64(withTerm) + 0.5)
+	return math.Log(1 + math.Abs(x))
+}
+
+// returns the field of a field ref, field names do not contain the separator unlike refs
+func lunrFieldName(fieldRef string) string {
+	return fieldRef[:strings.Index(fieldRef, "/")]
+}
+
+// compares strings by UTF-16 code units, the order of javascript's sort
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// splits lowercase text at white space and hyphens like lunr.tokenizer
+func lunrTokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == '-' || isJavaScriptSpace(r)
+	})
+}
+
+// reports whether r is matched by \s in a javascript regular expression
+func isJavaScriptSpace(r rune) bool {
+	switch r {
+	case '\t', '\n', '\v', '\f', '\r', ' ', '\u00a0', '\u1680', '\u2028', '\u2029', '\u202f', '\u205f', '\u3000', '\ufeff':
+		return true
+	}
+	return r >= '\u2000' && r <= '\u200a'
+}
+
+var (
+	lunrLeadingNonWord  = regexp.MustCompile(`^\W+`)
+	lunrTrailingNonWord = regexp.MustCompile(`\W+$`)
+)
+
+// english stop words of lunr.stopWordFilter
+var lunrStopWords = toSet(strings.Fields(`a able about across after all almost also am among an and any are as at
+	be because been but by can cannot could dear did do does either else ever every for from get got had has have
+	he her hers him his how however i if in into is it its just least let like likely may me might most must my
+	neither no nor not of off often on only or other our own rather said say says she should since so some than
+	that the their them then there these they this tis to too twas us wants was we were what when where which
+	while who whom why will with would yet you your`))
+
+func toSet(words []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// runs the tokens through lunr's trimmer, stop word filter and stemmer. Like lunr, tokens trimmed
+// to nothing are kept as empty terms.
+func lunrPipeline(tokens []string) []string {
+	var terms []string
+	for _, token := range tokens {
+		token = lunrTrailingNonWord.ReplaceAllString(lunrLeadingNonWord.ReplaceAllString(token, ""), "")
+		if lunrStopWords[token] {
+			continue
+		}
+		terms = append(terms, lunrStem(token))
+	}
+	return terms
+}
+
+// trims the characters that are not letters or digits around the tokens, the pipeline of the
+// languages other than english
+func lunrTrim(tokens []string) []string {
+	var terms []string
+	for _, token := range tokens {
+		terms = append(terms, strings.TrimFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}))
+	}
+	return terms
+}
+
+// reports whether the hugo language code is english, e.g. "en" or "en-us"
+func isEnglish(lang string) bool {
+	return languageAnalyzer(lang) == en.AnalyzerName
+}
+
+// regular expressions of lunr.stemmer, a Porter stemmer
+var (
+	stemConsonants = "[^aeiou][^aeiouy]*"
+	stemVowels     = "[aeiouy][aeiou]*"
+	stemMgr0       = regexp.MustCompile("^(" + stemConsonants + ")?" + stemVowels + stemConsonants)
+	stemMeq1       = regexp.MustCompile("^(" + stemConsonants + ")?" + stemVowels + stemConsonants + "(" + stemVowels + ")?$")
+	stemMgr1       = regexp.MustCompile("^(" + stemConsonants + ")?" + stemVowels + stemConsonants + stemVowels + stemConsonants)
+	stemSV         = regexp.MustCompile("^(" + stemConsonants + ")?[aeiouy]")
+
+	stem1a   = regexp.MustCompile(`^(.+?)(ss|i)es$`)
+	stem1a2  = regexp.MustCompile(`^(.+?)([^s])s$`)
+	stem1b   = regexp.MustCompile(`^(.+?)eed$`)
+	stem1b2  = regexp.MustCompile(`^(.+?)(ed|ing)$`)
+	stem1bAt = regexp.MustCompile(`(at|bl|iz)$`)
+	stemCVC  = regexp.MustCompile("^" + stemConsonants + "[aeiouy][^aeiouwxy]$")
+	stem1c   = regexp.MustCompile(`^(.+?[^aeiou])y$`)
+	stem2    = regexp.MustCompile(`^(.+?)(ational|tional|enci|anci|izer|bli|alli|entli|eli|ousli|ization|ation|ator|alism|iveness|fulness|ousness|aliti|iviti|biliti|logi)$`)
+	stem3    = regexp.MustCompile(`^(.+?)(icate|ative|alize|iciti|ical|ful|ness)$`)
+	stem4    = regexp.MustCompile(`^(.+?)(al|ance|ence|er|ic|able|ible|ant|ement|ment|ent|ou|ism|ate|iti|ous|ive|ize)$`)
+	stem4ion = regexp.MustCompile(`^(.+?)(s|t)(ion)$`)
+	stem5    = regexp.MustCompile(`^(.+?)e$`)
+
+	stem2Suffixes = map[string]string{
+		"ational": "ate", "tional": "tion", "enci": "ence", "anci": "ance", "izer": "ize", "bli": "ble",
+		"alli": "al", "entli": "ent", "eli": "e", "ousli": "ous", "ization": "ize", "ation": "ate",
+		"ator": "ate", "alism": "al", "iveness": "ive", "fulness": "ful", "ousness": "ous", "aliti": "al",
+		"iviti": "ive", "biliti": "ble", "logi": "log",
+	}
+	stem3Suffixes = map[string]string{
+		"icate": "ic", "ative": "", "alize": "al", "iciti": "ic", "ical": "ic", "ful": "", "ness": "",
+	}
+)
+
+// stems the word like lunr.stemmer, including its deviations from the original Porter algorithm
+func lunrStem(w string) string {
+	if len(utf16.Encode([]rune(w))) < 3 {
+		return w
+	}
+	initialY := w[0] == 'y'
+	if initialY {
+		w = "Y" + w[1:]
+	}
+
+	// step 1a
+	if m := stem1a.FindStringSubmatch(w); m != nil {
+		w = m[1] + m[2]
+	} else if m := stem1a2.FindStringSubmatch(w); m != nil {
+		w = m[1] + m[2]
+	}
+
+	// step 1b
+	if m := stem1b.FindStringSubmatch(w); m != nil {
+		if stemMgr0.MatchString(m[1]) {
+			w = trimLastRune(w)
+		}
+	} else if m := stem1b2.FindStringSubmatch(w); m != nil {
+		if stem := m[1]; stemSV.MatchString(stem) {
+			w = stem
+			if stem1bAt.MatchString(w) {
+				w += "e"
+			} else if hasDoubleConsonant(w) {
+				w = trimLastRune(w)
+			} else if stemCVC.MatchString(w) {
+				w += "e"
+			}
+		}
+	}
+
+	// step 1c
+	if m := stem1c.FindStringSubmatch(w); m != nil {
+		w = m[1] + "i"
+	}
+
+	// step 2
+	if m := stem2.FindStringSubmatch(w); m != nil && stemMgr0.MatchString(m[1]) {
+		w = m[1] + stem2Suffixes[m[2]]
+	}
+
+	// step 3
+	if m := stem3.FindStringSubmatch(w); m != nil && stemMgr0.MatchString(m[1]) {
+		w = m[1] + stem3Suffixes[m[2]]
+	}
+
+	// step 4
+	if m := stem4.FindStringSubmatch(w); m != nil {
+		if stemMgr1.MatchString(m[1]) {
+			w = m[1]
+		}
+	} else if m := stem4ion.FindStringSubmatch(w); m != nil {
+		if stem := m[1] + m[2]; stemMgr1.MatchString(stem) {
+			w = stem
+		}
+	}
+
+	// step 5
+	if m := stem5.FindStringSubmatch(w); m != nil {
+		stem := m[1]
+		if stemMgr1.MatchString(stem) || (stemMeq1.MatchString(stem) && !stemCVC.MatchString(stem)) {
+			w = stem
+		}
+	}
+	if strings.HasSuffix(w, "ll") && stemMgr1.MatchString(w) {
+		w = trimLastRune(w)
+	}
+
+	if initialY {
+		w = "y" + w[1:]
+	}
+	return w
+}
+
+// reports whether the word ends with a double consonant other than l, s or z, the
+// regular expression ([^aeiouylsz])\1$ of lunr.stemmer
+func hasDoubleConsonant(w string) bool {
+	runes := []rune(w)
+	n := len(runes)
+	return n >= 2 && runes[n-1] == runes[n-2] && !strings.ContainsRune("aeiouylsz", runes[n-1])
+}
+
+// removes the last character of the word
+func trimLastRune(w string) string {
+	_, size := utf8.DecodeLastRuneInString(w)
+	return w[:len(w)-size]
+}
@@ -0,0 +1,140 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// pages of the golden test and the indexes that lunr.js builds from them (see test/lunr/build.js)
+const (
+	testLunrPagesPath = "../test/lunr/pages.json"
+	testLunrIndexPath = "../test/lunr/index.json"
+)
+
+// checks the stemmer against the vocabulary of the Porter algorithm and lunr's deviations
+func TestLunrStem(t *testing.T) {
+	stems := map[string]string{
+		"caresses": "caress", "ponies": "poni", "cats": "cat", "feed": "feed", "agreed": "agre",
+		"plastered": "plaster", "motoring": "motor", "sing": "sing", "conflated": "conflat",
+		"troubled": "troubl", "sized": "size", "hopping": "hop", "falling": "fall", "hissing": "hiss",
+		"filing": "file", "happy": "happi", "say": "say", "relational": "relat", "conditional": "condit",
+		"digitizer": "digit", "vietnamization": "vietnam", "operator": "oper", "decisiveness": "decis",
+		"hopefulness": "hope", "sensibiliti": "sensibl", "triplicate": "triplic", "formative": "form",
+		"electrical": "electr", "goodness": "good", "allowance": "allow", "adjustable": "adjust",
+		"replacement": "replac", "adoption": "adopt", "effective": "effect", "probate": "probat",
+		"rate": "rate", "cease": "ceas", "controll": "control", "roll": "roll", "yelling": "yell",
+		"is": "is",
+
+		// lunr replaces a final y after any consonant but the first letter
+		"sky": "ski",
+	}
+	for word, expected := range stems {
+		if actual := lunrStem(word); actual != expected {
+			t.Errorf("%s: expected: %q, was: %q", word, expected, actual)
+		}
+	}
+}
+
+// checks that tokens are trimmed, stop words removed and terms stemmed
+func TestLunrPipeline(t *testing.T) {
+	expected := []string{"search", "index", "hugo", "site"}
+	actual := lunrPipeline(lunrTokenize("Searching the (indexes) of\u00a0Hugo-sites"))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %q, was: %q", expected, actual)
+	}
+}
+
+// checks that the terms are sorted and every field of a term has postings
+func TestLunrBuild(t *testing.T) {
+	builder := newLunrBuilder([]string{"title", "content"}, map[string]float64{"title": 2}, "en")
+	builder.add("/b/", map[string]interface{}{"title": "Zebra", "content": "apple zebra"})
+	builder.add("/a/", map[string]interface{}{"title": "Apple"})
+	index := builder.build()
+
+	if len(index.FieldVectors) != 4 {
+		t.Errorf("Expected: 4 field vectors, was: %d", len(index.FieldVectors))
+	}
+	var terms []string
+	for _, entry := range index.InvertedIndex {
+		terms = append(terms, entry[0].(string))
+		posting := entry[1].(map[string]interface{})
+		if _, ok := posting["content"]; !ok {
+			t.Errorf("Expected content postings for %q", entry[0])
+		}
+	}
+	if expected := []string{"appl", "zebra"}; !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Expected: %q, was: %q", expected, terms)
+	}
+}
+
+// checks that the words of other languages are trimmed but not filtered or stemmed like english
+func TestLunrBuildLanguage(t *testing.T) {
+	builder := newLunrBuilder([]string{"title"}, nil, "fr")
+	builder.add("/a/", map[string]interface{}{"title": "Les maisons (été)"})
+	index := builder.build()
+
+	var terms []string
+	for _, entry := range index.InvertedIndex {
+		terms = append(terms, entry[0].(string))
+	}
+	if expected := []string{"les", "maisons", "été"}; !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Expected: %q, was: %q", expected, terms)
+	}
+	if len(index.Pipeline) != 0 {
+		t.Errorf("Expected no search pipeline, was: %q", index.Pipeline)
+	}
+	if !isEnglish("en-us") || isEnglish("fr") {
+		t.Error("Expected only en-us to be english")
+	}
+}
+
+// reads the JSON file at path into v
+func readTestJSON(t *testing.T, path string, v interface{}) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checks that the serialized indexes are the ones that lunr.js builds from the same pages
+func TestLunrGolden(t *testing.T) {
+	var pages struct {
+		Fields    []string           `json:"fields"`
+		Boosts    map[string]float64 `json:"boosts"`
+		Languages map[string][]struct {
+			Ref string `json:"ref"`
+			PageEntry
+		} `json:"languages"`
+	}
+	readTestJSON(t, testLunrPagesPath, &pages)
+	if !reflect.DeepEqual(pages.Fields, exportedFields) || !reflect.DeepEqual(pages.Boosts, Config{}.fieldBoosts()) {
+		t.Fatalf("Expected the exported fields and boosts in %s", testLunrPagesPath)
+	}
+	var indexes map[string]map[string]interface{}
+	readTestJSON(t, testLunrIndexPath, &indexes)
+
+	for lang, entries := range pages.Languages {
+		builder := newLunrBuilder(pages.Fields, pages.Boosts, lang)
+		for i := range entries {
+			builder.add(entries[i].Ref, exportedValues(&entries[i].PageEntry))
+		}
+		data, err := json.Marshal(builder.build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual map[string]interface{}
+		if err := json.Unmarshal(data, &actual); err != nil {
+			t.Fatal(err)
+		}
+		for key, expected := range indexes[lang] {
+			if !reflect.DeepEqual(actual[key], expected) {
+				t.Errorf("%s %s: expected: %v, was: %v", lang, key, expected, actual[key])
+			}
+		}
+	}
+}
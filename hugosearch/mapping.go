@@ -0,0 +1,148 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/gohugoio/hugo/parser/metadecoders"
+)
+
+const (
+	// field holding the prefixes of the words of the title, for suggestions
+	suggestField = "title_suggest"
+
+	// analyzer of the suggest field, splits the words of the title into their prefixes
+	suggestAnalyzer = "suggest"
+
+	// length of the longest prefixes of the suggest field, in characters
+	suggestMaxPrefix = 20
+)
+
+// creates the mapping of the index, text is analyzed for the language of the pages
+func (ix *Indexer) newIndexMapping(lang string) (mapping.IndexMapping, error) {
+	if ix.opts.MappingFile != "" {
+		return loadIndexMapping(ix.opts.MappingFile, lang)
+	}
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.DefaultAnalyzer = languageAnalyzer(lang)
+	indexMapping.DefaultMapping = newPageMapping(ix.opts.Config.indexedParams())
+	if err := addSuggestAnalyzer(indexMapping); err != nil {
+		return nil, err
+	}
+	return indexMapping, nil
+}
+
+// adds the analyzer of the suggest field: "Lorem" is indexed as "l", "lo", "lor"...
+func addSuggestAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+	err := indexMapping.AddCustomTokenFilter("suggest_edge_ngram", map[string]interface{}{
+		"type": edgengram.Name,
+		"min":  1.0,
+		"max":  float64(suggestMaxPrefix),
+	})
+	if err != nil {
+		return err
+	}
+	return indexMapping.AddCustomAnalyzer(suggestAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name, "suggest_edge_ngram"},
+	})
+}
+
+// maps the fields of PageEntry: facet fields are keywords, so that "Marty Schoch"
+// remains one term, and the content keeps its term vectors for highlighting
+func newPageMapping(indexedParams []indexedParam) *mapping.DocumentMapping {
+	pageMapping := bleve.NewDocumentMapping()
+
+	// the title is also indexed by prefix for suggestions
+	suggest := bleve.NewTextFieldMapping()
+	suggest.Name = suggestField
+	suggest.Analyzer = suggestAnalyzer
+	suggest.Store = false
+	suggest.IncludeInAll = false
+	suggest.IncludeTermVectors = false
+	pageMapping.AddFieldMappingsAt("title", bleve.NewTextFieldMapping(), suggest)
+	pageMapping.AddFieldMappingsAt("content", bleve.NewTextFieldMapping())
+	pageMapping.AddFieldMappingsAt("summary", bleve.NewTextFieldMapping())
+	pageMapping.AddFieldMappingsAt("description", bleve.NewTextFieldMapping())
+	pageMapping.AddFieldMappingsAt("heading", bleve.NewTextFieldMapping())
+
+	// links are only displayed or looked up
+	for _, name := range []string{"permalink", "rel_permalink", "related"} {
+		pageMapping.AddFieldMappingsAt(name, storedField())
+	}
+
+	for _, name := range []string{"kind", "type", "section", "keywords", "author", "lang", "parent"} {
+		pageMapping.AddFieldMappingsAt(name, keywordField())
+	}
+	for _, name := range []string{"word_count", "reading_time", weightField} {
+		pageMapping.AddFieldMappingsAt(name, bleve.NewNumericFieldMapping())
+	}
+	for _, name := range []string{"date", "last_modified"} {
+		pageMapping.AddFieldMappingsAt(name, bleve.NewDateTimeFieldMapping())
+	}
+
+	params := bleve.NewDocumentMapping()
+	for _, param := range indexedParams {
+		params.AddFieldMappingsAt(param.Name, param.fieldMapping())
+	}
+	pageMapping.AddSubDocumentMapping("params", params)
+
+	// the fields of these objects depend on the site, they are all keywords
+	for _, name := range []string{"translations", "taxonomies"} {
+		keywords := bleve.NewDocumentMapping()
+		keywords.DefaultAnalyzer = keyword.Name
+		pageMapping.AddSubDocumentMapping(name, keywords)
+	}
+	return pageMapping
+}
+
+// returns a field that is stored for the search results but not searchable
+func storedField() *mapping.FieldMapping {
+	field := bleve.NewTextFieldMapping()
+	field.Index = false
+	field.IncludeInAll = false
+	field.IncludeTermVectors = false
+	return field
+}
+
+// returns a text field that is indexed as a single term, for facets and filters
+func keywordField() *mapping.FieldMapping {
+	field := bleve.NewTextFieldMapping()
+	field.Analyzer = keyword.Name
+	return field
+}
+
+// reads the index mapping from a file in bleve's mapping format, when the file
+// does not set a default analyzer, the analyzer of the language is used
+func loadIndexMapping(path string, lang string) (mapping.IndexMapping, error) {
+	values, err := metadecoders.Default.UnmarshalFileToMap(sourceFs, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := values["default_analyzer"]; !found {
+		values["default_analyzer"] = languageAnalyzer(lang)
+	}
+
+	// the decoded map is converted back to JSON, which bleve knows to read
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	indexMapping := bleve.NewIndexMapping()
+	err = json.Unmarshal(data, indexMapping)
+	if err == nil {
+		err = indexMapping.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %v", path, err)
+	}
+	return indexMapping, nil
+}
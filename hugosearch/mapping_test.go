@@ -0,0 +1,47 @@
+package hugosearch
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+// checks that facet fields are not split into words
+func TestKeywordFacet(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+	index := openIndex(t, opts.IndexPath)
+	defer index.Close()
+
+	request := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	request.AddFacet("author", bleve.NewFacetRequest("author", 10))
+	result, err := index.Search(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Author1Page2, Author2Page2"
+	for _, term := range result.Facets["author"].Terms {
+		if term.Term == expected {
+			return
+		}
+	}
+	t.Errorf("Expected author facet %q, was: %v", expected, result.Facets["author"].Terms)
+}
+
+// checks that the mapping is read from a YAML file and gets the analyzer of the language
+func TestLoadIndexMapping(t *testing.T) {
+	loaded, err := loadIndexMapping("../test/mapping.yaml", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexMapping := loaded.(*mapping.IndexMappingImpl)
+
+	if indexMapping.DefaultAnalyzer != "de" {
+		t.Errorf("Expected: %q, was: %q", "de", indexMapping.DefaultAnalyzer)
+	}
+	content := indexMapping.DefaultMapping.Properties["content"].Fields[0]
+	if content.Store || !content.Index {
+		t.Errorf("Expected content indexed and not stored, was: %+v", content)
+	}
+}
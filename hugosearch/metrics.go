@@ -0,0 +1,264 @@
+package hugosearch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upper bounds in seconds of the buckets of the request durations, the default buckets of prometheus
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// label of the requests that no handler answers, so that unknown paths do not add endpoints
+const unknownEndpoint = "other"
+
+// metrics counts the requests of the server, exposed with the state of the indexes by GET /metrics
+// in the text format of prometheus (https://prometheus.io/docs/instrumenting/exposition_formats/)
+type metrics struct {
+	mutex     sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[string]*histogram
+	zeroHits  map[string]uint64
+}
+
+// endpoint and status code of the requests
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+// histogram counts the observed values in cumulative buckets
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[requestKey]uint64),
+		latencies: make(map[string]*histogram),
+		zeroHits:  make(map[string]uint64),
+	}
+}
+
+// counts a request answered by the endpoint and its duration
+func (m *metrics) observeRequest(endpoint string, code int, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[requestKey{endpoint, code}]++
+	h := m.latencies[endpoint]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latencies[endpoint] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// counts a query of the endpoint that found nothing
+func (m *metrics) observeZeroHits(endpoint string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.zeroHits[endpoint]++
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range latencyBuckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// returns a handler counting the requests answered by the mux by endpoint, the pattern of the mux
+func (s *Server) instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		endpoint := unknownEndpoint
+		if _, pattern := mux.Handler(req); pattern != "" {
+			endpoint = pattern
+		}
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		mux.ServeHTTP(recorder, req)
+		s.metrics.observeRequest(endpoint, recorder.code, time.Since(start))
+	})
+}
+
+// metricsHandler answers GET /metrics with the metrics of the requests and of the served indexes
+type metricsHandler struct {
+	server *Server
+}
+
+func newMetricsHandler(server *Server) *metricsHandler {
+	return &metricsHandler{server: server}
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	h.server.metrics.write(w)
+	h.server.writeIndexMetrics(w)
+}
+
+// writes the metrics of the requests, sorted by their labels
+func (m *metrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeHeader(w, "hugosearch_requests_total", "counter", "Requests answered by endpoint and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		writeSample(w, "hugosearch_requests_total", labels("endpoint", key.endpoint, "code", strconv.Itoa(key.code)), float64(m.requests[key]))
+	}
+
+	writeHeader(w, "hugosearch_request_duration_seconds", "histogram", "Time taken to answer the requests by endpoint.")
+	endpoints := make([]string, 0, len(m.latencies))
+	for endpoint := range m.latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		h := m.latencies[endpoint]
+		for i, bound := range latencyBuckets {
+			writeSample(w, "hugosearch_request_duration_seconds_bucket", labels("endpoint", endpoint, "le", formatFloat(bound)), float64(h.counts[i]))
+		}
+		writeSample(w, "hugosearch_request_duration_seconds_bucket", labels("endpoint", endpoint, "le", "+Inf"), float64(h.count))
+		writeSample(w, "hugosearch_request_duration_seconds_sum", labels("endpoint", endpoint), h.sum)
+		writeSample(w, "hugosearch_request_duration_seconds_count", labels("endpoint", endpoint), float64(h.count))
+	}
+
+	writeHeader(w, "hugosearch_zero_hit_queries_total", "counter", "Queries that found nothing by endpoint.")
+	endpoints = endpoints[:0]
+	for endpoint := range m.zeroHits {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		writeSample(w, "hugosearch_zero_hit_queries_total", labels("endpoint", endpoint), float64(m.zeroHits[endpoint]))
+	}
+}
+
+// indexMetrics is the state of a served index
+type indexMetrics struct {
+	name  string
+	docs  uint64
+	size  int64
+	build *buildInfo
+}
+
+// writes the documents, size and last build of the served indexes, by the name they are registered with.
+// Indexes that cannot be read are left out, builds are only known for indexes built since they are recorded.
+func (s *Server) writeIndexMetrics(w io.Writer) {
+	var indexes []indexMetrics
+	for lang, index := range s.indexes {
+		m := indexMetrics{name: s.servedIndexName(lang)}
+		var err error
+		if m.docs, err = index.DocCount(); err != nil {
+			s.log.Printf("WARN: Cannot count documents of index %s: %v", m.name, err)
+			continue
+		}
+		if m.size, err = dirSize(index.indexPath()); err != nil {
+			s.log.Printf("WARN: Cannot read size of index %s: %v", m.name, err)
+			continue
+		}
+		if m.build, err = readBuildInfo(index); err != nil {
+			s.log.Printf("WARN: Cannot read last build of index %s: %v", m.name, err)
+		}
+		indexes = append(indexes, m)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].name < indexes[j].name })
+
+	writeHeader(w, "hugosearch_index_documents", "gauge", "Documents of the served index, pages and sections of pages.")
+	for _, m := range indexes {
+		writeSample(w, "hugosearch_index_documents", labels("index", m.name), float64(m.docs))
+	}
+	writeHeader(w, "hugosearch_index_size_bytes", "gauge", "Size of the files of the served index on disk.")
+	for _, m := range indexes {
+		writeSample(w, "hugosearch_index_size_bytes", labels("index", m.name), float64(m.size))
+	}
+	writeHeader(w, "hugosearch_index_last_build_duration_seconds", "gauge", "Time taken by the last build of the served index.")
+	for _, m := range indexes {
+		if m.build != nil {
+			writeSample(w, "hugosearch_index_last_build_duration_seconds", labels("index", m.name), m.build.Duration.Seconds())
+		}
+	}
+	writeHeader(w, "hugosearch_index_last_build_timestamp_seconds", "gauge", "Unix time of the end of the last build of the served index.")
+	for _, m := range indexes {
+		if m.build != nil {
+			writeSample(w, "hugosearch_index_last_build_timestamp_seconds", labels("index", m.name), float64(m.build.Time.UnixNano())/1e9)
+		}
+	}
+}
+
+// returns the total size of the files in the directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func writeHeader(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name string, labels string, value float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// escapes the label values of the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// returns the labels of a sample from their names and values
+func labels(namesAndValues ...string) string {
+	var pairs []string
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, namesAndValues[i], labelEscaper.Replace(namesAndValues[i+1])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
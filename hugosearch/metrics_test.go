@@ -0,0 +1,112 @@
+package hugosearch
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a sample of the text format: name, labels and value
+var sampleLine = regexp.MustCompile(`^([a-z_]+)\{((?:[a-z]+="(?:[^"\\]|\\.)*",?)*)\} (\S+)$`)
+
+// scrapes the metrics of the handler, returns the values by name and labels
+func scrapeMetrics(t *testing.T, handler http.Handler) map[string]float64 {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost/metrics", nil)
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, was: %d", http.StatusOK, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected text format of prometheus, was: %q", contentType)
+	}
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSuffix(recorder.Body.String(), "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		match := sampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("Invalid sample: %q", line)
+		}
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			t.Fatalf("Invalid value: %q", line)
+		}
+		samples[match[1]+"{"+match[2]+"}"] = value
+	}
+	return samples
+}
+
+func TestMetricsHandler(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+	handler := server.Handler()
+
+	for _, q := range []string{"page", "nothingmatches", ""} {
+		request, _ := http.NewRequest("GET", "http://localhost/api/search?q="+q, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	request, _ := http.NewRequest("GET", "http://localhost/unknown", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	samples := scrapeMetrics(t, handler)
+	expected := map[string]float64{
+		`hugosearch_requests_total{endpoint="/api/search",code="200"}`:                 2,
+		`hugosearch_requests_total{endpoint="/api/search",code="400"}`:                 1,
+		`hugosearch_requests_total{endpoint="other",code="404"}`:                       1,
+		`hugosearch_request_duration_seconds_bucket{endpoint="/api/search",le="+Inf"}`: 3,
+		`hugosearch_request_duration_seconds_count{endpoint="/api/search"}`:            3,
+		`hugosearch_zero_hit_queries_total{endpoint="/api/search"}`:                    1,
+		`hugosearch_index_documents{index="search.bleve"}`:                             3,
+	}
+	for name, value := range expected {
+		if samples[name] != value {
+			t.Errorf("Expected %s %v, was: %v", name, value, samples[name])
+		}
+	}
+	if samples[`hugosearch_index_size_bytes{index="search.bleve"}`] <= 0 {
+		t.Error("Expected size of the index")
+	}
+	if d := samples[`hugosearch_index_last_build_duration_seconds{index="search.bleve"}`]; d <= 0 {
+		t.Errorf("Expected duration of the last build, was: %v", d)
+	}
+	built := time.Unix(int64(samples[`hugosearch_index_last_build_timestamp_seconds{index="search.bleve"}`]), 0)
+	if time.Since(built) > time.Minute {
+		t.Errorf("Expected time of the last build, was: %v", built)
+	}
+
+	// the scrape itself is counted by the next one
+	samples = scrapeMetrics(t, handler)
+	if n := samples[`hugosearch_requests_total{endpoint="/metrics",code="200"}`]; n != 1 {
+		t.Errorf("Expected 1 scrape, was: %v", n)
+	}
+}
+
+func TestHistogramObserve(t *testing.T) {
+	h := &histogram{counts: make([]uint64, len(latencyBuckets))}
+	h.observe(0.003)
+	h.observe(0.2)
+	h.observe(20)
+
+	// buckets are cumulative, values above the last bound are only in +Inf
+	if h.counts[0] != 1 || h.counts[5] != 2 || h.counts[len(latencyBuckets)-1] != 2 || h.count != 3 {
+		t.Errorf("Expected cumulative counts, was: %v, count %d", h.counts, h.count)
+	}
+	if math.Abs(h.sum-20.203) > 1e-9 {
+		t.Errorf("Expected sum 20.203, was: %v", h.sum)
+	}
+}
+
+func TestLabelsEscaped(t *testing.T) {
+	expected := `q="a\"b\\c\n",code="200"`
+	if actual := labels("q", "a\"b\\c\n", "code", "200"); actual != expected {
+		t.Errorf("Expected: %s, was: %s", expected, actual)
+	}
+}
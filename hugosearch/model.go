@@ -0,0 +1,129 @@
+package hugosearch
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gohugoio/hugo/common/types"
+	"github.com/gohugoio/hugo/helpers"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/hugolib/paths"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// PageEntry maps the hugo internal page structure to a JSON structure
+// that blevesearch can understand.
+type PageEntry struct {
+	Title        string    `json:"title"`
+	Kind         string    `json:"kind"`
+	Type         string    `json:"type"`
+	Section      string    `json:"section"`
+	Content      string    `json:"content"`
+	Summary      string    `json:"summary"`
+	Description  string    `json:"description"`
+	Permalink    string    `json:"permalink"`
+	RelPermalink string    `json:"rel_permalink"`
+	WordCount    float64   `json:"word_count"`
+	ReadingTime  float64   `json:"reading_time"`
+	Keywords     []string  `json:"keywords"`
+	Date         time.Time `json:"date"`
+	LastModified time.Time `json:"last_modified"`
+	Author       string    `json:"author"`
+	Lang         string    `json:"lang"`
+
+	// links to the translations of the page, by language
+	Translations map[string]string `json:"translations"`
+
+	// terms of the taxonomies of the site, by plural name (e.g. "tags")
+	Taxonomies map[string][]string `json:"taxonomies"`
+
+	// heading of a section of the page and link to the page, when the page is split at its headings
+	Heading string `json:"heading,omitempty"`
+	Parent  string `json:"parent,omitempty"`
+
+	// front matter params listed in the search settings of the site, converted to their type
+	Params map[string]interface{} `json:"params"`
+
+	// multiplier of the score of the page set by search_weight in the front matter, not indexed when not set
+	SearchWeight *float64 `json:"search_weight,omitempty"`
+
+	// links to the pages related by the related content settings of the site, most related first
+	Related []string `json:"related,omitempty"`
+}
+
+// path settings of hugo by default, used to turn the taxonomy terms into keys
+var defaultPathSpec = &helpers.PathSpec{Paths: &paths.Paths{}}
+
+// returns the key hugo gives to the taxonomy term by default, e.g. "hugo-search" for
+// "Hugo Search", so that the terms written differently in the front matter match
+func taxonomyKey(term string) string {
+	return strings.ToLower(defaultPathSpec.MakePath(term))
+}
+
+// returns the distinct keys of the taxonomy terms, in order
+func taxonomyKeys(terms []string) []string {
+	var keys []string
+	for _, term := range terms {
+		if key := taxonomyKey(term); key != "" && !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// returns the index entry of the page
+func (ix *Indexer) newIndexEntry(p page.Page) *PageEntry {
+	var author string
+
+	switch str := p.Params()["author"].(type) {
+	case string:
+		author = str
+	case []string:
+		author = strings.Join(str, ", ")
+	}
+
+	var translations map[string]string
+	if len(p.Translations()) > 0 {
+		translations = make(map[string]string)
+		for _, t := range p.Translations() {
+			translations[t.Lang()] = t.RelPermalink()
+		}
+	}
+
+	var taxonomies map[string][]string
+	if list, ok := p.Site().Taxonomies().(hugolib.TaxonomyList); ok {
+		for plural := range list {
+			// a single term can be written as a string
+			if terms := types.ToStringSlicePreserveString(p.Params()[plural]); len(terms) > 0 {
+				if taxonomies == nil {
+					taxonomies = make(map[string][]string)
+				}
+				taxonomies[plural] = taxonomyKeys(terms)
+			}
+		}
+	}
+
+	return &PageEntry{
+		Title:        p.Title(),
+		Kind:         p.Kind(),
+		Type:         p.Type(),
+		Section:      p.Section(),
+		Content:      p.Plain(),
+		Summary:      helpers.StripHTML(string(p.Summary())),
+		Description:  p.Description(),
+		Permalink:    p.Permalink(),
+		RelPermalink: p.RelPermalink(),
+		WordCount:    float64(p.WordCount()),
+		ReadingTime:  float64(p.ReadingTime()),
+		Keywords:     p.Keywords(),
+		Date:         p.Date(),
+		LastModified: p.Lastmod(),
+		Author:       author,
+		Lang:         p.Lang(),
+		Translations: translations,
+		Taxonomies:   taxonomies,
+		Params:       ix.pageParams(p.Params(), p.RelPermalink()),
+		SearchWeight: ix.searchWeight(p.Params()[weightField], p.RelPermalink()),
+		Related:      ix.relatedLinks(p),
+	}
+}
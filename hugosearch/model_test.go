@@ -0,0 +1,111 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+var expected *PageEntry
+
+func setUp() {
+	myDate, _ := time.Parse(time.RFC3339, "2015-12-09T22:15:11+01:00")
+	expected = &PageEntry{
+		Kind:         "page",
+		Type:         "page",
+		Section:      "",
+		Content:      "Lorem ipsum Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n",
+		Summary:      "Lorem ipsum Lorem ipsum dolor sit amet, consectetur adipiscing elit.",
+		WordCount:    10,
+		ReadingTime:  1,
+		Keywords:     []string{"keyword"},
+		Date:         myDate,
+		LastModified: myDate,
+		Lang:         "en",
+	}
+}
+
+// test single author
+func TestNewPageForIndex(t *testing.T) {
+	setUp()
+	expected.Title = "Title-page-1"
+	expected.Permalink = "http://localhost/page1/"
+	expected.RelPermalink = "/page1/"
+	expected.Author = "Author1Page1"
+	expected.Taxonomies = map[string][]string{"tags": {"tag1", "tag2"}, "topics": {"topic1", "topic2"}}
+	expected.Related = []string{"/page2/", "/fail/no-title/"}
+	actual := NewIndexer(testOptions(t, Config{})).newIndexEntry(findPage(t, expected.Title))
+	comparePages(t, actual, expected)
+}
+
+// test multiple authors
+func TestNewPageForIndex2(t *testing.T) {
+	setUp()
+	expected.Title = "Title-page-2"
+	expected.Description = "Description of page 2"
+	expected.Permalink = "http://localhost/page2/"
+	expected.RelPermalink = "/page2/"
+	expected.Author = "Author1Page2, Author2Page2"
+	expected.Taxonomies = map[string][]string{"tags": {"tag1"}}
+	weight := 0.5
+	expected.SearchWeight = &weight
+	expected.Related = []string{"/page1/", "/fail/no-title/"}
+	actual := NewIndexer(testOptions(t, Config{})).newIndexEntry(findPage(t, expected.Title))
+	comparePages(t, actual, expected)
+}
+
+func comparePages(t *testing.T, actual *PageEntry, expected *PageEntry) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Error("Values don't match!")
+		printPage("Expected:", expected)
+		printPage("but was:", actual)
+	}
+}
+
+// find first page with specified title
+func findPage(t testing.TB, title string) page.Page {
+	pages := readTestPages(t, Config{})
+	for _, page := range pages {
+		if page.Title() == title {
+			return page
+		}
+	}
+	return nil
+}
+
+// print struct as pretty formatted json
+func printPage(label string, page *PageEntry) {
+	fmt.Println(label)
+	data, _ := json.MarshalIndent(page, "", "    ")
+	os.Stdout.Write(data)
+	fmt.Println()
+}
+
+// test front matter params listed in the settings
+func TestNewPageForIndexParams(t *testing.T) {
+	setUp()
+	expected.Title = "Title-page-1"
+	expected.Permalink = "http://localhost/page1/"
+	expected.RelPermalink = "/page1/"
+	expected.Author = "Author1Page1"
+	expected.Taxonomies = map[string][]string{"tags": {"tag1", "tag2"}, "topics": {"topic1", "topic2"}}
+	expected.Related = []string{"/page2/", "/fail/no-title/"}
+	expected.Params = map[string]interface{}{"topics": []string{"topic1", "topic2"}}
+	ix := NewIndexer(testOptions(t, Config{Params: []string{"topics:list", "missing"}}))
+	actual := ix.newIndexEntry(findPage(t, expected.Title))
+	comparePages(t, actual, expected)
+}
+
+// checks that taxonomy terms are turned into the keys hugo gives them
+func TestTaxonomyKeys(t *testing.T) {
+	actual := taxonomyKeys([]string{"Hugo Search", "Go", "go", "C++", "Été!"})
+	expected := []string{"hugo-search", "go", "c++", "été"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %v, was: %v", expected, actual)
+	}
+}
@@ -0,0 +1,128 @@
+package hugosearch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/spf13/cast"
+)
+
+// types of the front matter params, written after the name of the param in the settings
+const (
+	paramString = "string"
+	paramList   = "list"
+	paramNumber = "number"
+	paramDate   = "date"
+	paramBool   = "bool"
+)
+
+// indexedParam is a front matter param listed in the settings as "name" or "name:type"
+type indexedParam struct {
+	Name string
+	Type string
+}
+
+// parses "name" or "name:type", the type is string by default
+func parseIndexedParam(spec string) (indexedParam, error) {
+	param := indexedParam{Name: spec, Type: paramString}
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		param.Name, param.Type = spec[:i], spec[i+1:]
+	}
+	if param.Name == "" {
+		return param, fmt.Errorf("missing name of param %q", spec)
+	}
+	switch param.Type {
+	case paramString, paramList, paramNumber, paramDate, paramBool:
+		return param, nil
+	}
+	return param, fmt.Errorf("unknown type of param %q, must be one of string, list, number, date, bool", spec)
+}
+
+// returns the params listed in the settings, which were validated when read
+func (c Config) indexedParams() (params []indexedParam) {
+	for _, spec := range c.Params {
+		if param, err := parseIndexedParam(spec); err == nil {
+			params = append(params, param)
+		}
+	}
+	return
+}
+
+// returns the field mapping of the param: strings and lists are keywords, for facets and filters
+func (param indexedParam) fieldMapping() *mapping.FieldMapping {
+	switch param.Type {
+	case paramNumber:
+		return bleve.NewNumericFieldMapping()
+	case paramDate:
+		return bleve.NewDateTimeFieldMapping()
+	case paramBool:
+		return bleve.NewBooleanFieldMapping()
+	}
+	return keywordField()
+}
+
+// checks if the param is indexed as keywords, which are facets and filters of the search API
+func (param indexedParam) keyword() bool {
+	return param.Type == paramString || param.Type == paramList
+}
+
+// converts the front matter value to the type of the param, reports false if it cannot be converted
+func (param indexedParam) convert(value interface{}) (interface{}, bool) {
+	switch param.Type {
+	case paramString:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case []string:
+			return strings.Join(v, ", "), true
+		case int, int64, float64, bool:
+			return fmt.Sprint(v), true
+		}
+	case paramList:
+		switch v := value.(type) {
+		case string:
+			return []string{v}, true
+		case []string:
+			return v, true
+		case []interface{}:
+			list, err := cast.ToStringSliceE(v)
+			return list, err == nil
+		}
+	case paramNumber:
+		number, err := cast.ToFloat64E(value)
+		return number, err == nil
+	case paramDate:
+		date, err := cast.ToTimeE(value)
+		return date, err == nil
+	case paramBool:
+		b, err := cast.ToBoolE(value)
+		return b, err == nil
+	}
+	return nil, false
+}
+
+// returns the params of the page converted to their type, values that cannot be converted are skipped
+func (ix *Indexer) pageParams(frontMatter map[string]interface{}, link string) map[string]interface{} {
+	var params map[string]interface{}
+	for _, param := range ix.opts.Config.indexedParams() {
+		// hugo lowers the keys of the front matter
+		value, found := frontMatter[strings.ToLower(param.Name)]
+		if !found {
+			continue
+		}
+		converted, ok := param.convert(value)
+		if !ok {
+			if ix.verbose {
+				ix.log.Printf("WARN: Param %s of %s is not a %s: %v", param.Name, link, param.Type, value)
+			}
+			continue
+		}
+		if params == nil {
+			params = make(map[string]interface{})
+		}
+		params[param.Name] = converted
+	}
+	return params
+}
@@ -0,0 +1,69 @@
+package hugosearch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIndexedParam(t *testing.T) {
+	valid := map[string]indexedParam{
+		"product":        {"product", paramString},
+		"audience:list":  {"audience", paramList},
+		"version:number": {"version", paramNumber},
+		"released:date":  {"released", paramDate},
+		"beta:bool":      {"beta", paramBool},
+	}
+	for spec, expected := range valid {
+		actual, err := parseIndexedParam(spec)
+		if err != nil || actual != expected {
+			t.Errorf("Expected: %v, was: %v (%v)", expected, actual, err)
+		}
+	}
+	for _, spec := range []string{"", ":list", "product:text"} {
+		if _, err := parseIndexedParam(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+// checks that the values are converted to the type of the param, values of another type are skipped
+func TestPageParams(t *testing.T) {
+	ix := NewIndexer(testOptions(t, Config{Params: []string{"product", "version:number", "audience:list", "difficulty:string", "released:date", "beta:bool"}}))
+	actual := ix.newIndexEntry(findPage(t, "Title-page-3")).Params
+	expected := map[string]interface{}{
+		"product":    "hugo-search",
+		"version":    1.2,
+		"audience":   []string{"admins"},
+		"difficulty": "3",
+		"released":   time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected: %v, was: %v", expected, actual)
+	}
+}
+
+// checks that string and list params are facets and filters of the search API, by their name after
+// param. so that a param named like another parameter is not mistaken for it
+func TestQueryHandlerParams(t *testing.T) {
+	server := newTestServer(t, Config{Params: []string{"product", "audience:list", "difficulty:number", "page"}})
+	defer server.Close()
+
+	_, response := getSearch(t, server, "q=lorem&param.audience=admins")
+	if response.Total != 1 || response.Hits[0].URL != "/parent1/page3/" {
+		t.Errorf("Expected 1 hit for audience admins, was: %+v", response)
+	}
+	if facet := response.Facets["param.product"]; len(facet) != 1 || facet[0].Term != "hugo-search" {
+		t.Errorf("Expected product facet, was: %+v", response.Facets)
+	}
+
+	_, response = getSearch(t, server, "q=lorem&audience=users&page=1&param.page=2")
+	if response.Total != 0 || response.Page != 1 {
+		t.Errorf("Expected page 1 of the hits of param page 2, was: %+v", response)
+	}
+
+	_, response = getSearch(t, server, "q=params.difficulty:>2")
+	if response.Total != 1 {
+		t.Errorf("Expected 1 hit with difficulty > 2, was: %+v", response)
+	}
+}
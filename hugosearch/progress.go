@@ -0,0 +1,60 @@
+package hugosearch
+
+import (
+	"fmt"
+	"time"
+)
+
+// interval between the progress reports of a build
+const progressInterval = 5 * time.Second
+
+// progress of the build of an index, reported every progressInterval with the pages indexed per
+// second and the estimated time until the other pages are indexed
+type progress struct {
+	logging
+	path     string
+	total    int
+	done     int
+	start    time.Time
+	reported time.Time
+}
+
+func (ix *Indexer) newProgress(path string, total int) *progress {
+	now := time.Now()
+	return &progress{logging: ix.logging, path: path, total: total, start: now, reported: now}
+}
+
+// counts a page as indexed and reports the progress when it was not reported for progressInterval
+func (p *progress) add(now time.Time) {
+	p.done++
+	if p.done < p.total && now.Sub(p.reported) >= progressInterval {
+		p.reported = now
+		p.log.Println(p.report(now))
+	}
+}
+
+// returns the report of the progress at the time
+func (p *progress) report(now time.Time) string {
+	rate := p.rate(now)
+	eta := "unknown"
+	if rate > 0 {
+		eta = time.Duration(float64(p.total-p.done) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("Indexing %s: %d/%d pages, %.0f pages/s, ETA %s", p.path, p.done, p.total, rate, eta)
+}
+
+// returns the pages indexed per second since the start
+func (p *progress) rate(now time.Time) float64 {
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.done) / elapsed
+}
+
+// reports the pages indexed, their time and rate when verbose
+func (p *progress) finish(now time.Time) {
+	if p.verbose {
+		p.log.Printf("Indexed %d pages of %s in %s, %.0f pages/s", p.done, p.path, now.Sub(p.start).Round(time.Millisecond), p.rate(now))
+	}
+}
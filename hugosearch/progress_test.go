@@ -0,0 +1,20 @@
+package hugosearch
+
+import (
+	"testing"
+	"time"
+)
+
+// checks the rate and the estimated time of the progress report
+func TestProgressReport(t *testing.T) {
+	start := time.Now()
+	p := &progress{path: "search.bleve", total: 100, done: 25, start: start, reported: start}
+	expected := "Indexing search.bleve: 25/100 pages, 5 pages/s, ETA 15s"
+	if actual := p.report(start.Add(5 * time.Second)); actual != expected {
+		t.Errorf("Expected: %s, was: %s", expected, actual)
+	}
+	expected = "Indexing search.bleve: 25/100 pages, 0 pages/s, ETA unknown"
+	if actual := p.report(start); actual != expected {
+		t.Errorf("Expected: %s, was: %s", expected, actual)
+	}
+}
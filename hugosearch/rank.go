@@ -0,0 +1,233 @@
+package hugosearch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/spf13/cast"
+)
+
+// field of the multiplier of the score of a page, set by search_weight in the front matter
+const weightField = "search_weight"
+
+// number of hits rescored by the weight of their page and the age of their date, the hits beyond
+// cannot be requested
+const rescoreWindow = 1000
+
+// errRescoreWindow is returned for the hits beyond the rescored ones, which would not follow the
+// order of the first pages
+var errRescoreWindow = fmt.Errorf("only the first %d hits can be requested when sorted by score", rescoreWindow)
+
+// Recency lowers the score of the hits with the age of their date: the score is halved every
+// HalfLife, but not below Min times the score
+type Recency struct {
+	// half-life of the score, a duration like "4320h" or a number of days like "180d", no decay when empty
+	HalfLife string
+
+	// field of the date, date (default) or last_modified
+	Field string
+
+	// lowest factor of the score, between 0 and 1
+	Min float64
+}
+
+// checks the settings of the recency decay
+func (r Recency) validate() error {
+	if _, err := parseHalfLife(r.HalfLife); err != nil {
+		return err
+	}
+	switch r.Field {
+	case "", "date", "last_modified":
+	default:
+		return fmt.Errorf("invalid recency field %q, must be date or last_modified", r.Field)
+	}
+	if r.Min < 0 || r.Min > 1 {
+		return fmt.Errorf("invalid recency min %v, must be between 0 and 1", r.Min)
+	}
+	return nil
+}
+
+// parses a duration that can also be a number of days, e.g. "180d"
+func parseHalfLife(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	var halfLife time.Duration
+	var err error
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		var n float64
+		n, err = strconv.ParseFloat(days, 64)
+		halfLife = time.Duration(n * float64(24*time.Hour))
+	} else {
+		halfLife, err = time.ParseDuration(value)
+	}
+	if err != nil || halfLife <= 0 {
+		return 0, fmt.Errorf("invalid recency half-life %q, must be a positive duration like 4320h or 180d", value)
+	}
+	return halfLife, nil
+}
+
+// ranking rescores the hits sorted by score with the weight of their page and the recency decay
+type ranking struct {
+	halfLife time.Duration
+	field    string
+	min      float64
+}
+
+// returns the ranking of the settings, which were validated by newConfig
+func (c Config) ranking() ranking {
+	halfLife, _ := parseHalfLife(c.Recency.HalfLife)
+	r := ranking{halfLife: halfLife, field: c.Recency.Field, min: c.Recency.Min}
+	if r.field == "" {
+		r.field = "date"
+	}
+	return r
+}
+
+// reports whether the hits of the index are rescored: when there is a recency decay or a page has a weight
+func (r ranking) applies(index bleve.Index) (bool, error) {
+	if r.halfLife > 0 {
+		return true, nil
+	}
+	fields, err := index.Fields()
+	if err != nil {
+		return false, err
+	}
+	return containsString(fields, weightField), nil
+}
+
+// returns the factor of the score of a hit from its stored weight and date
+func (r ranking) factor(fields map[string]interface{}, now time.Time) float64 {
+	factor := 1.0
+	if weight, ok := fields[weightField].(float64); ok {
+		factor = weight
+	}
+	if r.halfLife > 0 {
+		if value, ok := fields[r.field].(string); ok {
+			if date, err := time.Parse(time.RFC3339, value); err == nil && date.Before(now) {
+				decay := math.Pow(0.5, float64(now.Sub(date))/float64(r.halfLife))
+				factor *= math.Max(decay, r.min)
+			}
+		}
+	}
+	return factor
+}
+
+// multiplies the scores of the hits by their factor and sorts them by score, the hits need the
+// weight and date fields
+func (r ranking) rescore(hits search.DocumentMatchCollection, now time.Time) float64 {
+	maxScore := 0.0
+	for _, hit := range hits {
+		hit.Score *= r.factor(hit.Fields, now)
+		maxScore = math.Max(maxScore, hit.Score)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return maxScore
+}
+
+// searches the request, the first rescoreWindow hits are rescored when it is sorted by score and
+// the hits beyond are rejected with errRescoreWindow. The window is searched for the scores, then
+// the hits of the requested page for their fields.
+func (r ranking) search(index bleve.Index, request *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	rescored, err := r.applies(index)
+	if err != nil {
+		return nil, err
+	}
+	if !rescored || !sortedByScore(request.Sort) {
+		return index.Search(request)
+	}
+	if request.From+request.Size > rescoreWindow {
+		return nil, errRescoreWindow
+	}
+
+	window := *request
+	window.Size, window.From = rescoreWindow, 0
+	window.Fields = []string{weightField, r.field}
+	window.Highlight = nil
+	matches, err := index.Search(&window)
+	if err != nil {
+		return nil, err
+	}
+	maxScore := r.rescore(matches.Hits, time.Now())
+
+	from := request.From
+	if from > len(matches.Hits) {
+		from = len(matches.Hits)
+	}
+	hits := matches.Hits[from:]
+	if len(hits) > request.Size {
+		hits = hits[:request.Size]
+	}
+	result := &bleve.SearchResult{Status: matches.Status, Hits: search.DocumentMatchCollection{}}
+	if len(hits) > 0 {
+		result, err = searchHits(index, request, hits)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Request = request
+	result.Total = matches.Total
+	result.MaxScore = maxScore
+	result.Took += matches.Took
+	result.Facets = matches.Facets
+	return result, nil
+}
+
+// searches the fields and highlights of the hits, which keep their order and scores
+func searchHits(index bleve.Index, request *bleve.SearchRequest, hits search.DocumentMatchCollection) (*bleve.SearchResult, error) {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	page := *request
+	page.Query = bleve.NewConjunctionQuery(request.Query, bleve.NewDocIDQuery(ids))
+	page.Size, page.From = len(ids), 0
+	page.Facets = nil
+	result, err := index.Search(&page)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]*search.DocumentMatch)
+	for _, hit := range result.Hits {
+		found[hit.ID] = hit
+	}
+	result.Hits = search.DocumentMatchCollection{}
+	for _, hit := range hits {
+		if match := found[hit.ID]; match != nil {
+			match.Score = hit.Score
+			result.Hits = append(result.Hits, match)
+		}
+	}
+	return result, nil
+}
+
+// checks if the hits are sorted by descending score
+func sortedByScore(order search.SortOrder) bool {
+	if len(order) == 0 {
+		return true
+	}
+	score, ok := order[0].(*search.SortScore)
+	return ok && score.Desc
+}
+
+// returns the search weight of the front matter, reported and ignored unless it is a positive number
+func (ix *Indexer) searchWeight(value interface{}, link string) *float64 {
+	if value == nil {
+		return nil
+	}
+	weight, err := cast.ToFloat64E(value)
+	if err != nil || weight <= 0 {
+		if ix.verbose {
+			ix.log.Printf("WARN: %s of %s is not a positive number: %v", weightField, link, value)
+		}
+		return nil
+	}
+	return &weight
+}
@@ -0,0 +1,119 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	bleveHttp "github.com/blevesearch/bleve/http"
+)
+
+// checks that half-lives are durations or numbers of days
+func TestParseHalfLife(t *testing.T) {
+	for value, expected := range map[string]time.Duration{"": 0, "180d": 180 * 24 * time.Hour, "36h": 36 * time.Hour} {
+		if actual, err := parseHalfLife(value); err != nil || actual != expected {
+			t.Errorf("%q: expected: %v, was: %v (%v)", value, expected, actual, err)
+		}
+	}
+	for _, value := range []string{"soon", "-1d", "0h"} {
+		if _, err := parseHalfLife(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+// checks that the score is multiplied by the weight and halved every half-life, down to the minimum
+func TestRankingFactor(t *testing.T) {
+	now := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	r := Config{Recency: Recency{HalfLife: "10d", Min: 0.2}}.ranking()
+	tests := []struct {
+		fields   map[string]interface{}
+		expected float64
+	}{
+		{map[string]interface{}{}, 1},
+		{map[string]interface{}{weightField: 2.0}, 2},
+		{map[string]interface{}{weightField: 2.0, "date": "2020-01-21T00:00:00Z"}, 1},
+		{map[string]interface{}{"date": "2019-01-01T00:00:00Z"}, 0.2},
+		{map[string]interface{}{"date": "2021-01-01T00:00:00Z"}, 1},
+	}
+	for _, test := range tests {
+		if actual := r.factor(test.fields, now); math.Abs(actual-test.expected) > 1e-9 {
+			t.Errorf("%v: expected: %v, was: %v", test.fields, test.expected, actual)
+		}
+	}
+}
+
+// checks that the hits of the search handler are rescored by the search_weight of their page (0.5 for page 2)
+func TestSearchHandlerWeight(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	body := `{"query":{"query":"lorem"},"fields":["title"]}`
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+	newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	var result bleve.SearchResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+
+	var searchRequest bleve.SearchRequest
+	json.Unmarshal([]byte(body), &searchRequest)
+	searchRequest.Query = boostQuery(searchRequest.Query, server.boosts)
+	unweighted, err := bleveHttp.IndexByName(testIndexName).Search(&searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := make(map[string]float64)
+	for _, hit := range unweighted.Hits {
+		scores[hit.ID] = hit.Score
+	}
+
+	if len(result.Hits) != len(unweighted.Hits) {
+		t.Fatalf("Expected: %d hits, was: %d", len(unweighted.Hits), len(result.Hits))
+	}
+	for i, hit := range result.Hits {
+		expected := scores[hit.ID]
+		if hit.ID == "/page2/" {
+			expected *= 0.5
+		}
+		if math.Abs(hit.Score-expected) > 1e-9 {
+			t.Errorf("%s: expected score: %v, was: %v", hit.ID, expected, hit.Score)
+		}
+		if i > 0 && hit.Score > result.Hits[i-1].Score {
+			t.Errorf("Expected hits sorted by score, was: %v", result.Hits)
+		}
+		if hit.Fields["title"] == nil || hit.Fields[weightField] != nil {
+			t.Errorf("Expected only the requested fields, was: %v", hit.Fields)
+		}
+	}
+}
+
+// checks that the hits beyond the rescored ones are rejected, unless they are not sorted by score
+func TestRankingWindow(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+	index := bleveHttp.IndexByName(testIndexName)
+
+	request := bleve.NewSearchRequestOptions(bleve.NewQueryStringQuery("lorem"), 2, rescoreWindow-1, false)
+	if _, err := server.ranking.search(index, request); err != errRescoreWindow {
+		t.Errorf("Expected: %v, was: %v", errRescoreWindow, err)
+	}
+	request.SortBy([]string{"-date"})
+	if _, err := server.ranking.search(index, request); err != nil {
+		t.Errorf("Expected hits sorted by date, was: %v", err)
+	}
+
+	if code, _ := getSearch(t, server, "q=lorem&collapse=false&size=100&page=11"); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, was: %d", http.StatusBadRequest, code)
+	}
+	if code, _ := getSearch(t, server, "q=lorem&collapse=false&size=100&page=10"); code != http.StatusOK {
+		t.Errorf("Expected status %d, was: %d", http.StatusOK, code)
+	}
+}
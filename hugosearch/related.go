@@ -0,0 +1,243 @@
+package hugosearch
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+const (
+	defaultRelatedSize = 5
+	maxRelatedSize     = 20
+
+	// number of the most frequent terms of the content searched in the similar pages
+	relatedContentTerms = 25
+
+	// number of documents searched for the similar pages, the hits of their sections are collapsed
+	relatedWindow = 100
+
+	// boost of the keywords and the terms of the taxonomies over the terms of the content
+	relatedTermBoost = 2.0
+)
+
+// sources of the related pages
+const (
+	// the pages related by hugo, stored when the page was indexed
+	relatedByHugo = "hugo"
+
+	// the pages sharing keywords, taxonomy terms and content terms with the page
+	relatedBySimilarity = "similar"
+)
+
+// relatedHandler answers GET /api/related?url=...&size=...&lang=... with the pages related to the
+// page at url: the pages found by hugo's related content settings in the order of hugo, or the pages
+// most similar by their keywords, taxonomy terms and content when hugo found none
+type relatedHandler struct {
+	server       *Server
+	defaultIndex string
+}
+
+func newRelatedHandler(server *Server, defaultIndex string) *relatedHandler {
+	return &relatedHandler{server: server, defaultIndex: defaultIndex}
+}
+
+// relatedResponse is the response of GET /api/related
+type relatedResponse struct {
+	URL    string      `json:"url"`
+	Source string      `json:"source"`
+	Hits   []searchHit `json:"hits"`
+}
+
+func (h *relatedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := req.URL.Query()
+	index := h.server.requestedIndex(w, params, h.defaultIndex)
+	if index == nil {
+		return
+	}
+	link := pageLink(params.Get("url"))
+	if link == "" {
+		h.server.showError(w, "missing parameter url", http.StatusBadRequest)
+		return
+	}
+	size, err := intParam(params.Get("size"), defaultRelatedSize, 1, maxRelatedSize)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("invalid parameter size: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	request := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{link}))
+	request.Fields = []string{"*"}
+	found, err := index.Search(request)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error executing query: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(found.Hits) == 0 {
+		h.server.showError(w, fmt.Sprintf("no such page '%s'", link), http.StatusNotFound)
+		return
+	}
+	fields := found.Hits[0].Fields
+
+	response := &relatedResponse{URL: link, Source: relatedByHugo, Hits: []searchHit{}}
+	hits, err := relatedPages(index, stringsField(fields, "related"), size)
+	if err == nil && len(hits) == 0 {
+		response.Source = relatedBySimilarity
+		hits, err = similarPages(index, link, fields, size)
+	}
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error executing query: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, hit := range hits {
+		response.Hits = append(response.Hits, newSearchHit(hit))
+	}
+	h.server.writeJSON(w, response)
+}
+
+// returns the first size pages of the links that are in the index, in the order of the links
+func relatedPages(index bleve.Index, links []string, size int) (search.DocumentMatchCollection, error) {
+	var hits search.DocumentMatchCollection
+	for _, link := range links {
+		hits = append(hits, &search.DocumentMatch{ID: link})
+	}
+	return pageHits(index, hits, size)
+}
+
+// returns the first size pages sharing keywords, taxonomy terms or frequent content terms with the
+// stored fields of the page at link, the best scored first
+func similarPages(index bleve.Index, link string, fields map[string]interface{}, size int) (search.DocumentMatchCollection, error) {
+	indexFields, err := index.Fields()
+	if err != nil {
+		return nil, err
+	}
+	var terms []query.Query
+	addTerms := func(field string, values []string, boost float64) {
+		for _, value := range values {
+			term := bleve.NewTermQuery(value)
+			term.SetField(field)
+			term.SetBoost(boost)
+			terms = append(terms, term)
+		}
+	}
+	addTerms("keywords", stringsField(fields, "keywords"), relatedTermBoost)
+	for _, taxonomy := range indexedTaxonomies(indexFields) {
+		addTerms(taxonomyPrefix+taxonomy, stringsField(fields, taxonomyPrefix+taxonomy), relatedTermBoost)
+	}
+	addTerms("content", contentTerms(index, stringField(fields, "content"), relatedContentTerms), 1)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// neither the page nor its sections are similar pages
+	parent := bleve.NewTermQuery(link)
+	parent.SetField("parent")
+	excluded := []query.Query{bleve.NewDocIDQuery([]string{link}), parent}
+	q := query.NewBooleanQuery([]query.Query{bleve.NewDisjunctionQuery(terms...)}, nil, excluded)
+
+	matches, err := index.Search(bleve.NewSearchRequestOptions(q, relatedWindow, 0, false))
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	var hits search.DocumentMatchCollection
+	for _, hit := range matches.Hits {
+		if page := pageLink(hit.ID); !found[page] {
+			found[page] = true
+			hits = append(hits, &search.DocumentMatch{ID: page, Score: hit.Score})
+		}
+	}
+	return pageHits(index, hits, size)
+}
+
+// searches the fields of the first size hits that are in the index, which keep their order and scores
+func pageHits(index bleve.Index, hits search.DocumentMatchCollection, size int) (search.DocumentMatchCollection, error) {
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	request := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	request.Fields = hitFields
+	result, err := searchHits(index, request, hits)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Hits) > size {
+		return result.Hits[:size], nil
+	}
+	return result.Hits, nil
+}
+
+// returns the most frequent terms of the content, analyzed as the content field of the index.
+// There are none when the index has several mappings, like the combined index of the languages.
+func contentTerms(index bleve.Index, content string, n int) []string {
+	indexMapping := index.Mapping()
+	if indexMapping == nil || content == "" {
+		return nil
+	}
+	analyzer := indexMapping.AnalyzerNamed(indexMapping.AnalyzerNameForPath("content"))
+	if analyzer == nil {
+		return nil
+	}
+	counts := make(map[string]int)
+	var terms []string
+	for _, token := range analyzer.Analyze([]byte(content)) {
+		// single letters and digits are not worth searching
+		term := string(token.Term)
+		if utf8.RuneCountInString(term) < 2 {
+			continue
+		}
+		if counts[term] == 0 {
+			terms = append(terms, term)
+		}
+		counts[term]++
+	}
+	sort.SliceStable(terms, func(i, j int) bool { return counts[terms[i]] > counts[terms[j]] })
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// returns the values of the stored field, a single value is a list of one
+func stringsField(fields map[string]interface{}, name string) []string {
+	switch value := fields[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return values
+	}
+	return nil
+}
+
+// returns the links of the pages related to p by the related content settings of the site, at most
+// maxRelatedSize. Errors are reported and the page has no related pages.
+func (ix *Indexer) relatedLinks(p page.Page) []string {
+	related, err := p.Site().RegularPages().Related(p)
+	if err != nil {
+		if ix.verbose {
+			ix.log.Printf("WARN: related pages of %s not found: %v", p.RelPermalink(), err)
+		}
+		return nil
+	}
+	var links []string
+	for _, r := range related {
+		if len(links) == maxRelatedSize {
+			break
+		}
+		links = append(links, r.RelPermalink())
+	}
+	return links
+}
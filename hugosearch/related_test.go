@@ -0,0 +1,65 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sends the GET request to the related API of the test index
+func getRelated(t *testing.T, server *Server, params string) (int, *relatedResponse) {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost/api/related?"+params, nil)
+	newRelatedHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, nil
+	}
+	var response relatedResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	return recorder.Code, &response
+}
+
+func TestRelatedHandlerHugo(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	// page1 and page2 share their keyword, /fail/no-title/ is related by hugo but not indexed
+	_, response := getRelated(t, server, "url=/page1/")
+	if response.Source != relatedByHugo || len(response.Hits) != 1 || response.Hits[0].URL != "/page2/" {
+		t.Errorf("Expected /page2/ related by hugo, was: %+v", response)
+	}
+	if response.Hits[0].Title != "Title-page-2" || response.Hits[0].Summary != "Description of page 2" {
+		t.Errorf("Expected title and description of /page2/, was: %+v", response.Hits[0])
+	}
+}
+
+func TestRelatedHandlerSimilar(t *testing.T) {
+	server := newTestServer(t, Config{SplitHeadings: true})
+	defer server.Close()
+
+	_, response := getRelated(t, server, "url=/parent1/page3/&size=1")
+	if response.Source != relatedBySimilarity || len(response.Hits) != 1 {
+		t.Fatalf("Expected 1 similar page, was: %+v", response)
+	}
+	if url := response.Hits[0].URL; url != "/page1/" && url != "/page2/" {
+		t.Errorf("Expected a page sharing the terms of the content, was: %+v", response.Hits[0])
+	}
+}
+
+func TestRelatedHandlerBadRequest(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	for _, params := range []string{"", "url=/page1/&size=0", "url=/page1/&size=100"} {
+		if code, _ := getRelated(t, server, params); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %q, was: %d", http.StatusBadRequest, params, code)
+		}
+	}
+	if code, _ := getRelated(t, server, "url=/missing/"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown page, was: %d", http.StatusNotFound, code)
+	}
+}
@@ -0,0 +1,318 @@
+package hugosearch
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/gohugoio/hugo/parser/metadecoders"
+	"github.com/mitchellh/mapstructure"
+)
+
+// field set on the pinned hits of the best bets
+const pinnedField = "pinned"
+
+// Banner is shown with the results of the queries matching a rule of the best bets
+type Banner struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// rule of the best bets, applied to the queries matching its pattern
+type rule struct {
+	// exact (default), prefix or regex, queries are compared in lowercase with single spaces
+	Match string
+	Query string
+
+	// links of the pages shown first in this order, or removed from the hits, e.g. /pricing/
+	Pin  []string
+	Hide []string
+
+	Banner *Banner
+
+	pattern *regexp.Regexp
+}
+
+// checks if the rule applies to the normalized query
+func (r *rule) matches(q string) bool {
+	switch r.Match {
+	case "prefix":
+		return strings.HasPrefix(q, r.Query)
+	case "regex":
+		return r.pattern.MatchString(q)
+	}
+	return q == r.Query
+}
+
+// returns the query in lowercase with single spaces
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// reads the rules of the best bets from a JSON, YAML or TOML file with a list of rules
+func readRules(path string) ([]*rule, error) {
+	values, err := metadecoders.Default.UnmarshalFileToMap(sourceFs, path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []*rule
+	}
+	if err := mapstructure.WeakDecode(values, &file); err != nil {
+		return nil, fmt.Errorf("invalid rules %s: %v", path, err)
+	}
+	for i, r := range file.Rules {
+		switch r.Match {
+		case "", "exact", "prefix":
+			r.Query = normalizeQuery(r.Query)
+		case "regex":
+			if r.pattern, err = regexp.Compile("(?i)" + r.Query); err != nil {
+				return nil, fmt.Errorf("invalid rule %d in %s: %v", i+1, path, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid rule %d in %s: unknown match %q, must be exact, prefix or regex", i+1, path, r.Match)
+		}
+	}
+	return file.Rules, nil
+}
+
+// bestBets holds the rules of the rules file, which is read again when it was modified
+type bestBets struct {
+	logging
+	path    string
+	mutex   sync.Mutex
+	modTime time.Time
+	rules   []*rule
+}
+
+// reads the rules file, a server without rules file has no best bets
+func newBestBets(l logging, path string) (*bestBets, error) {
+	b := &bestBets{logging: l, path: path}
+	if path == "" {
+		return b, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if b.rules, err = readRules(path); err != nil {
+		return nil, err
+	}
+	b.modTime = info.ModTime()
+	return b, nil
+}
+
+// returns the rules, read again when the file was modified. The previous rules are kept when it is invalid.
+func (b *bestBets) current() []*rule {
+	if b.path == "" {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil || info.ModTime().Equal(b.modTime) {
+		return b.rules
+	}
+	b.modTime = info.ModTime()
+	rules, err := readRules(b.path)
+	if err != nil {
+		b.log.Println("WARN: Rules not reloaded:", err)
+		return b.rules
+	}
+	b.log.Println("Reloaded rules:", b.path)
+	b.rules = rules
+	return rules
+}
+
+// bestBet is the result of the rules matching a query
+type bestBet struct {
+	pinned  []string
+	hidden  []string
+	banners []Banner
+}
+
+// returns the best bet of the rules matching the query, nil when none does
+func (b *bestBets) match(q string) *bestBet {
+	q = normalizeQuery(q)
+	var bet *bestBet
+	for _, r := range b.current() {
+		if !r.matches(q) {
+			continue
+		}
+		if bet == nil {
+			bet = &bestBet{}
+		}
+		bet.pinned = appendMissing(bet.pinned, r.Pin...)
+		bet.hidden = appendMissing(bet.hidden, r.Hide...)
+		if r.Banner != nil {
+			bet.banners = append(bet.banners, *r.Banner)
+		}
+	}
+	return bet
+}
+
+// returns the text of a query on all fields, the first one of a conjunction or disjunction
+func queryText(q query.Query) string {
+	switch q := q.(type) {
+	case *query.QueryStringQuery:
+		return q.Query
+	case *query.MatchQuery:
+		if q.FieldVal == "" {
+			return q.Match
+		}
+	case *query.MatchPhraseQuery:
+		if q.FieldVal == "" {
+			return q.MatchPhrase
+		}
+	case *query.ConjunctionQuery:
+		for _, conjunct := range q.Conjuncts {
+			if text := queryText(conjunct); text != "" {
+				return text
+			}
+		}
+	case *query.DisjunctionQuery:
+		for _, disjunct := range q.Disjuncts {
+			if text := queryText(disjunct); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// appends the values that are not in the list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !containsString(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// returns the query of the pinned pages with the ids that pass the filters of q, so that
+// the filters of a search remove the pinned pages like the other hits
+func pinnedQuery(ids []string, q query.Query) query.Query {
+	return bleve.NewConjunctionQuery(append([]query.Query{bleve.NewDocIDQuery(ids)}, queryFilters(q)...)...)
+}
+
+// returns the filters of a conjunction, like the sections and terms of search.js: the
+// conjuncts that do not search the text. Other queries have no filters.
+func queryFilters(q query.Query) []query.Query {
+	conjunction, ok := q.(*query.ConjunctionQuery)
+	if !ok {
+		return nil
+	}
+	var filters []query.Query
+	for _, conjunct := range conjunction.Conjuncts {
+		if !searchesText(conjunct) {
+			filters = append(filters, conjunct)
+		}
+	}
+	return filters
+}
+
+// reports whether q searches the text of the pages: query strings, matches on all fields
+// and the queries combining them
+func searchesText(q query.Query) bool {
+	switch q := q.(type) {
+	case *query.QueryStringQuery:
+		return true
+	case *query.MatchQuery:
+		return q.FieldVal == ""
+	case *query.MatchPhraseQuery:
+		return q.FieldVal == ""
+	case *query.BooleanQuery:
+		return searchesText(q.Must) || searchesText(q.Should)
+	case *query.ConjunctionQuery:
+		for _, conjunct := range q.Conjuncts {
+			if searchesText(conjunct) {
+				return true
+			}
+		}
+	case *query.DisjunctionQuery:
+		for _, disjunct := range q.Disjuncts {
+			if searchesText(disjunct) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searches the hits from..from+size of q with the best bet: the pinned pages come first and are
+// marked, the hidden pages and the sections of both are removed from the other hits. searchHits
+// searches the other hits, searchPinned the documents of the pinned pages.
+func (bet *bestBet) search(q query.Query, from int, size int,
+	searchHits func(q query.Query, from int, size int) (*bleve.SearchResult, error),
+	searchPinned func(ids []string) (*bleve.SearchResult, error)) (*bleve.SearchResult, error) {
+
+	if bet == nil {
+		return searchHits(q, from, size)
+	}
+
+	var pinned search.DocumentMatchCollection
+	if len(bet.pinned) > 0 {
+		found, err := searchPinned(bet.pinned)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]*search.DocumentMatch)
+		for _, hit := range found.Hits {
+			byID[hit.ID] = hit
+		}
+		for _, link := range bet.pinned {
+			if hit := byID[link]; hit != nil && !containsString(bet.hidden, link) {
+				if hit.Fields == nil {
+					hit.Fields = make(map[string]interface{})
+				}
+				hit.Fields[pinnedField] = true
+				pinned = append(pinned, hit)
+			}
+		}
+	}
+
+	var excluded []query.Query
+	for _, links := range [][]string{bet.hidden, bet.pinned} {
+		for _, link := range links {
+			parent := bleve.NewTermQuery(link)
+			parent.SetField("parent")
+			excluded = append(excluded, bleve.NewDocIDQuery([]string{link}), parent)
+		}
+	}
+	if len(excluded) > 0 {
+		q = query.NewBooleanQuery([]query.Query{q}, nil, excluded)
+	}
+
+	// the pinned hits take the first places, the other hits follow
+	hitsFrom := from - len(pinned)
+	if hitsFrom < 0 {
+		hitsFrom = 0
+	}
+	hitsSize := from + size - len(pinned) - hitsFrom
+	if hitsSize < 0 {
+		hitsSize = 0
+	}
+	result, err := searchHits(q, hitsFrom, hitsSize)
+	if err != nil {
+		return nil, err
+	}
+	start, end := from, from+size
+	if start > len(pinned) {
+		start = len(pinned)
+	}
+	if end > len(pinned) {
+		end = len(pinned)
+	}
+	result.Hits = append(pinned[start:end:end], result.Hits...)
+	result.Total += uint64(len(pinned))
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRulesPath = "../test/rules.yaml"
+
+// checks that the queries match the exact, prefix and regex rules
+func TestBestBetsMatch(t *testing.T) {
+	bets, err := newBestBets(newLogging(Options{}), testRulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query    string
+		expected *bestBet
+	}{
+		{" LOREM ", &bestBet{pinned: []string{"/page2/"}, hidden: []string{"/page1/"}, banners: []Banner{{Title: "Lorem ipsum", URL: "/page2/"}}}},
+		{"title-page", &bestBet{pinned: []string{"/parent1/page3/", "/missing/"}}},
+		{"Dolor  Sit", &bestBet{banners: []Banner{{Text: "Dolor"}}}},
+		{"lorem ipsum", nil},
+	}
+	for _, test := range tests {
+		if actual := bets.match(test.query); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q: expected: %+v, was: %+v", test.query, test.expected, actual)
+		}
+	}
+}
+
+// checks that the rules are read again when the file is modified, and kept when it becomes invalid
+func TestBestBetsReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	writeRules := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	now := time.Now()
+	writeRules("[[rules]]\nquery = \"pricing\"\npin = [\"/pricing/\"]\n", now.Add(-time.Hour))
+	bets, err := newBestBets(newLogging(Options{}), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRules("[[rules]]\nquery = \"pricing\"\npin = [\"/plans/\"]\n", now)
+	if bet := bets.match("pricing"); bet == nil || bet.pinned[0] != "/plans/" {
+		t.Errorf("Expected rules reloaded, was: %+v", bet)
+	}
+	writeRules("[[rules]]\nmatch = \"glob\"\n", now.Add(time.Hour))
+	if bet := bets.match("pricing"); bet == nil || bet.pinned[0] != "/plans/" {
+		t.Errorf("Expected previous rules kept, was: %+v", bet)
+	}
+}
+
+// checks that pinned pages come first and hidden pages are removed, with the banners of the rules
+func TestQueryHandlerBestBets(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.RulesFile = testRulesPath
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	_, response := getSearch(t, server, "q=lorem")
+	if response.Total != 2 || len(response.Hits) != 2 || response.Hits[0].URL != "/page2/" || !response.Hits[0].Pinned || response.Hits[1].Pinned {
+		t.Errorf("Expected /page2/ pinned first and /page1/ hidden, was: %+v", response)
+	}
+	if len(response.Banners) != 1 || response.Banners[0].Title != "Lorem ipsum" {
+		t.Errorf("Expected banner, was: %+v", response.Banners)
+	}
+
+	// the pinned page is not repeated on the next page
+	_, response = getSearch(t, server, "q=lorem&size=1&page=2")
+	if len(response.Hits) != 1 || response.Hits[0].URL == "/page2/" {
+		t.Errorf("Expected another page than /page2/, was: %+v", response.Hits)
+	}
+}
+
+// checks that the pinned pages outside of the filters of the request are left out
+func TestQueryHandlerBestBetsFiltered(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.RulesFile = testRulesPath
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	for _, filter := range []string{"section=parent1", "tags=tag2"} {
+		_, response := getSearch(t, server, "q=lorem&"+filter)
+		for _, hit := range response.Hits {
+			if hit.URL == "/page2/" || hit.Pinned {
+				t.Errorf("Expected /page2/ filtered out with %s, was: %+v", filter, response)
+			}
+		}
+		if response.Total != uint64(len(response.Hits)) {
+			t.Errorf("Expected total of the filtered hits with %s, was: %+v", filter, response)
+		}
+	}
+
+	// the pinned page matching the filters is kept
+	_, response := getSearch(t, server, "q=lorem&tags=tag1")
+	if len(response.Hits) == 0 || response.Hits[0].URL != "/page2/" || !response.Hits[0].Pinned {
+		t.Errorf("Expected /page2/ pinned first, was: %+v", response)
+	}
+}
+
+// checks that the search handler marks the pinned hits of the query of search.js and adds the banners
+func TestSearchHandlerBestBets(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.RulesFile = testRulesPath
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	body := `{"query":{"conjuncts":[{"boost":1,"query":"lorem"}]},"fields":["title"]}`
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+	newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	var result struct {
+		Hits []struct {
+			ID     string                 `json:"id"`
+			Fields map[string]interface{} `json:"fields"`
+		} `json:"hits"`
+		Banners []Banner `json:"banners"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	if len(result.Hits) != 2 || result.Hits[0].ID != "/page2/" || result.Hits[0].Fields[pinnedField] != true || len(result.Banners) != 1 {
+		t.Errorf("Expected /page2/ pinned first and a banner, was: %s", recorder.Body.String())
+	}
+}
+
+// checks that the search handler removes the pinned pages that do not pass the filters of the query
+func TestSearchHandlerBestBetsFiltered(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.RulesFile = testRulesPath
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	for filter, pinned := range map[string]bool{"tag2": false, "tag1": true} {
+		body := `{"query":{"conjuncts":[{"query":"lorem"},{"disjuncts":[{"term":"` + filter + `","field":"taxonomies.tags"}]}]}}`
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+		newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+		var result struct {
+			Total uint64 `json:"total_hits"`
+			Hits  []struct {
+				ID string `json:"id"`
+			} `json:"hits"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%v: %s", err, recorder.Body.String())
+		}
+		found := false
+		for _, hit := range result.Hits {
+			found = found || hit.ID == "/page2/"
+		}
+		if found != pinned || result.Total != uint64(len(result.Hits)) {
+			t.Errorf("Expected /page2/ pinned %v with %s, was: %s", pinned, filter, recorder.Body.String())
+		}
+	}
+}
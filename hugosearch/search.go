@@ -0,0 +1,208 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/blevesearch/bleve"
+	bleveHttp "github.com/blevesearch/bleve/http"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// boosts of the fields that rank higher than the others when searching all fields,
+// title > heading and keywords > summary > content
+var defaultFieldBoosts = map[string]float64{
+	"title":    2,
+	"heading":  1.5,
+	"keywords": 1.5,
+	"summary":  1.2,
+}
+
+// returns the default boosts of the fields, with the boosts of the settings
+func (c Config) fieldBoosts() map[string]float64 {
+	boosts := make(map[string]float64)
+	for field, boost := range defaultFieldBoosts {
+		boosts[field] = boost
+	}
+	for field, boost := range c.Boosts {
+		boosts[field] = boost
+	}
+	return boosts
+}
+
+// searchHandler executes bleve search requests like bleve's own handler, except
+// that queries on all fields also score the matches in the boosted fields
+type searchHandler struct {
+	server    *Server
+	indexName string
+}
+
+func newSearchHandler(server *Server, indexName string) *searchHandler {
+	return &searchHandler{server: server, indexName: indexName}
+}
+
+func (h *searchHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	index := bleveHttp.IndexByName(h.indexName)
+	if index == nil {
+		h.server.showError(w, fmt.Sprintf("no such index '%s'", h.indexName), http.StatusNotFound)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error reading request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var searchRequest bleve.SearchRequest
+	if err := json.Unmarshal(requestBody, &searchRequest); err != nil {
+		h.server.showError(w, fmt.Sprintf("error parsing query: %v", err), http.StatusBadRequest)
+		return
+	}
+	if q, ok := searchRequest.Query.(query.ValidatableQuery); ok {
+		if err := q.Validate(); err != nil {
+			h.server.showError(w, fmt.Sprintf("error validating query: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	bet := h.server.bestBets.match(queryText(searchRequest.Query))
+	searchRequest.Query = boostQuery(searchRequest.Query, h.server.boosts)
+
+	searchResponse, err := bet.search(searchRequest.Query, searchRequest.From, searchRequest.Size,
+		func(q query.Query, from int, size int) (*bleve.SearchResult, error) {
+			request := searchRequest
+			request.Query, request.From, request.Size = q, from, size
+			return h.server.ranking.search(index, &request)
+		},
+		func(ids []string) (*bleve.SearchResult, error) {
+			request := searchRequest
+			request.Query, request.From, request.Size, request.Facets = pinnedQuery(ids, searchRequest.Query), 0, len(ids), nil
+			return index.Search(&request)
+		})
+	if err == errRescoreWindow {
+		h.server.showError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error executing query: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if searchResponse.Total == 0 {
+		h.server.metrics.observeZeroHits("/api/" + h.indexName + "/_search")
+	}
+	searchResponse.Request = &searchRequest
+	if bet != nil && len(bet.banners) > 0 {
+		h.server.writeJSON(w, bannersResult{SearchResult: searchResponse, Banners: bet.banners})
+		return
+	}
+	h.server.writeJSON(w, searchResponse)
+}
+
+// bannersResult is a bleve search result with the banners of the best bets
+type bannersResult struct {
+	*bleve.SearchResult
+	Banners []Banner `json:"banners"`
+}
+
+// adds the boosted fields to the queries that search all fields. The original query
+// still decides which documents match, the boosted fields only add to their score.
+func boostQuery(q query.Query, boosts map[string]float64) query.Query {
+	switch q := q.(type) {
+	case *query.ConjunctionQuery:
+		for i, conjunct := range q.Conjuncts {
+			q.Conjuncts[i] = boostQuery(conjunct, boosts)
+		}
+	case *query.DisjunctionQuery:
+		for i, disjunct := range q.Disjuncts {
+			q.Disjuncts[i] = boostQuery(disjunct, boosts)
+		}
+	case *query.QueryStringQuery:
+		parsed, err := q.Parse()
+		if err != nil {
+			return q
+		}
+		return withFieldBoosts(q, searchedClauses(parsed), boosts)
+	case *query.MatchQuery:
+		if q.FieldVal == "" {
+			return withFieldBoosts(q, []query.Query{q}, boosts)
+		}
+	}
+	return q
+}
+
+// returns the matches and phrases of a parsed query string that search all fields: the
+// clauses on a field (title:word) and the excluded ones (-word) are left out
+func searchedClauses(q query.Query) []query.Query {
+	var clauses []query.Query
+	switch q := q.(type) {
+	case *query.BooleanQuery:
+		clauses = append(searchedClauses(q.Must), searchedClauses(q.Should)...)
+	case *query.ConjunctionQuery:
+		for _, conjunct := range q.Conjuncts {
+			clauses = append(clauses, searchedClauses(conjunct)...)
+		}
+	case *query.DisjunctionQuery:
+		for _, disjunct := range q.Disjuncts {
+			clauses = append(clauses, searchedClauses(disjunct)...)
+		}
+	case *query.MatchQuery:
+		if q.FieldVal == "" {
+			clauses = append(clauses, q)
+		}
+	case *query.MatchPhraseQuery:
+		if q.FieldVal == "" {
+			clauses = append(clauses, q)
+		}
+	}
+	return clauses
+}
+
+// returns a query matching the same documents as q, scored higher when its clauses on all fields
+// are found in a boosted field
+func withFieldBoosts(q query.Query, clauses []query.Query, boosts map[string]float64) query.Query {
+	var boosted []query.Query
+	for field, boost := range boosts {
+		for _, clause := range clauses {
+			switch clause := clause.(type) {
+			case *query.MatchQuery:
+				match := *clause
+				match.SetField(field)
+				match.SetBoost(boost)
+				boosted = append(boosted, &match)
+			case *query.MatchPhraseQuery:
+				phrase := *clause
+				phrase.SetField(field)
+				phrase.SetBoost(boost)
+				boosted = append(boosted, &phrase)
+			}
+		}
+	}
+	if len(boosted) == 0 {
+		return q
+	}
+	return query.NewBooleanQuery([]query.Query{q}, boosted, nil)
+}
+
+// reports an error to the client
+func (l logging) showError(w http.ResponseWriter, msg string, code int) {
+	if l.verbose {
+		l.log.Printf("Reporting error %v/%v", code, msg)
+	}
+	http.Error(w, msg, code)
+}
+
+// encodes the response as JSON
+func (l logging) writeJSON(w http.ResponseWriter, v interface{}) {
+	l.writeJSONStatus(w, v, http.StatusOK)
+}
+
+// encodes the response as JSON with the status code
+func (l logging) writeJSONStatus(w http.ResponseWriter, v interface{}, code int) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		l.log.Println("WARN: Cannot encode response:", err)
+	}
+}
@@ -0,0 +1,121 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// checks that a match in the title ranks higher than a match in the content
+func TestSearchHandlerBoostsTitle(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	// "page" is in the title of all pages and in the content of page 3 only
+	body := `{"query":{"query":"page 3"}}`
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+	newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	var result bleve.SearchResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	if len(result.Hits) == 0 || result.Hits[0].ID != "/parent1/page3/" {
+		t.Errorf("Expected /parent1/page3/ first, was: %v", result.Hits)
+	}
+}
+
+// checks that GET /api/search and _search rank the hits of a query alike
+func TestBoostsAgree(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	body := `{"query":{"conjuncts":[{"query":"page 3"},{"disjuncts":[{"term":"tag1","field":"taxonomies.tags"}]}]}}`
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+	newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+	var result bleve.SearchResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+
+	_, response := getSearch(t, server, "q=page+3&tags=tag1&collapse=false")
+	if len(response.Hits) == 0 || len(response.Hits) != len(result.Hits) {
+		t.Fatalf("Expected: %d hits, was: %+v", len(result.Hits), response.Hits)
+	}
+	for i, hit := range response.Hits {
+		if hit.URL != result.Hits[i].ID || math.Abs(hit.Score-result.Hits[i].Score) > 1e-9 {
+			t.Errorf("Expected %s (%v), was: %s (%v)", result.Hits[i].ID, result.Hits[i].Score, hit.URL, hit.Score)
+		}
+	}
+}
+
+// checks that the queries on all fields get the boosted fields
+func TestBoostQuery(t *testing.T) {
+	q := boostQuery(bleve.NewConjunctionQuery(bleve.NewQueryStringQuery("lorem"), bleve.NewTermQuery("page")), defaultFieldBoosts)
+	data, _ := json.Marshal(q)
+	if !strings.Contains(string(data), `"field":"title"`) {
+		t.Errorf("Expected a query on title, was: %s", data)
+	}
+}
+
+// checks that only the words of a query string searching all fields are boosted, not the field
+// names, the operators or the excluded words
+func TestBoostQueryString(t *testing.T) {
+	q := boostQuery(bleve.NewQueryStringQuery(`title:foo +bar -baz "a phrase"`), map[string]float64{"title": 2})
+	boolean, ok := q.(*query.BooleanQuery)
+	if !ok {
+		t.Fatalf("Expected boosted query, was: %T", q)
+	}
+	var boosted []string
+	for _, clause := range boolean.Should.(*query.DisjunctionQuery).Disjuncts {
+		switch clause := clause.(type) {
+		case *query.MatchQuery:
+			boosted = append(boosted, clause.FieldVal+":"+clause.Match)
+		case *query.MatchPhraseQuery:
+			boosted = append(boosted, clause.FieldVal+":"+clause.MatchPhrase)
+		}
+	}
+	if expected := []string{"title:bar", "title:a phrase"}; !reflect.DeepEqual(boosted, expected) {
+		t.Errorf("Expected: %q, was: %q", expected, boosted)
+	}
+
+	// a query on a field only is not boosted
+	if q := bleve.NewQueryStringQuery("title:foo"); boostQuery(q, defaultFieldBoosts) != q {
+		t.Errorf("Expected query on title unchanged")
+	}
+}
+
+// checks that a query on a field is scored without the boosts
+func TestSearchHandlerFieldQuery(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	body := `{"query":{"query":"title:3 -content:missing"}}`
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "http://localhost/api/"+testIndexName+"/_search", strings.NewReader(body))
+	newSearchHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	var result bleve.SearchResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	if result.Total != 1 || result.Hits[0].ID != "/parent1/page3/" {
+		t.Fatalf("Expected /parent1/page3/, was: %v", result.Hits)
+	}
+	expected, err := server.indexes[""].Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery("title:3 -content:missing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Hits[0].Score != expected.Hits[0].Score {
+		t.Errorf("Expected score %v, was: %v", expected.Hits[0].Score, result.Hits[0].Score)
+	}
+}
@@ -0,0 +1,51 @@
+package hugosearch
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gohugoio/hugo/helpers"
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/spf13/cast"
+)
+
+// matches the h2 and h3 headings rendered by hugo with their id, e.g. <h2 id="install">Install</h2>
+var headingPattern = regexp.MustCompile(`(?s)<h[23][^>]*\sid="([^"]+)"[^>]*>(.*?)</h[23]>`)
+
+// returns the entries of the sections of the page that start with a h2 or h3 heading,
+// identified by the link to the heading (e.g. /docs/foo/#install)
+func newSectionEntries(p page.Page, entry *PageEntry) (ids []string, entries []*PageEntry, err error) {
+	content, err := p.Content()
+	if err != nil {
+		return nil, nil, err
+	}
+	html := cast.ToString(content)
+
+	headings := headingPattern.FindAllStringSubmatchIndex(html, -1)
+	for i, heading := range headings {
+		end := len(html)
+		if i+1 < len(headings) {
+			end = headings[i+1][0]
+		}
+		anchor := html[heading[2]:heading[3]]
+
+		section := *entry
+		section.Heading = strings.TrimSpace(helpers.StripHTML(html[heading[4]:heading[5]]))
+		section.Content = strings.TrimSpace(helpers.StripHTML(html[heading[1]:end]))
+		section.Parent = entry.RelPermalink
+		section.Permalink = entry.Permalink + "#" + anchor
+		section.RelPermalink = entry.RelPermalink + "#" + anchor
+
+		ids = append(ids, section.RelPermalink)
+		entries = append(entries, &section)
+	}
+	return
+}
+
+// returns the link of the page of a document, which is the document of the page or of one of its sections
+func pageLink(id string) string {
+	if i := strings.Index(id, "#"); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
@@ -0,0 +1,53 @@
+package hugosearch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSectionEntries(t *testing.T) {
+	p := findPage(t, "Title-page-3")
+	ids, entries, err := newSectionEntries(p, NewIndexer(testOptions(t, Config{})).newIndexEntry(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 1 || ids[0] != "/parent1/page3/#lorem-ipsum" {
+		t.Fatalf("Expected section /parent1/page3/#lorem-ipsum, was: %v", ids)
+	}
+	section := entries[0]
+	if section.Heading != "Lorem ipsum" || section.Parent != "/parent1/page3/" || section.Permalink != "http://localhost/parent1/page3/#lorem-ipsum" {
+		t.Errorf("Expected heading, parent and permalink of the section, was: %+v", section)
+	}
+	if !strings.HasPrefix(section.Content, "Lorem ipsum dolor sit amet") {
+		t.Errorf("Expected content after the heading, was: %q", section.Content)
+	}
+}
+
+// checks that the hits of the sections are collapsed into one hit per page
+func TestQueryHandlerSplitHeadings(t *testing.T) {
+	server := newTestServer(t, Config{SplitHeadings: true})
+	defer server.Close()
+
+	_, response := getSearch(t, server, "q=dolor")
+	found := make(map[string]bool)
+	for _, hit := range response.Hits {
+		if found[pageLink(hit.URL)] {
+			t.Errorf("Expected one hit per page, was: %+v", response.Hits)
+		}
+		found[pageLink(hit.URL)] = true
+	}
+	if response.Total != 3 || len(found) != 3 {
+		t.Errorf("Expected 3 pages, was: %+v", response)
+	}
+
+	_, response = getSearch(t, server, "q=dolor&collapse=false")
+	if response.Total != 6 {
+		t.Errorf("Expected 3 pages and 3 sections, was: %+v", response)
+	}
+
+	_, response = getSearch(t, server, "q=dolor&size=2&page=2")
+	if response.Total != 3 || len(response.Hits) != 1 {
+		t.Errorf("Expected 1 hit on page 2, was: %+v", response)
+	}
+}
@@ -0,0 +1,316 @@
+package hugosearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	bleveHttp "github.com/blevesearch/bleve/http"
+	"github.com/rs/cors"
+)
+
+// time given to the requests in progress to complete when the server is stopped
+const shutdownTimeout = 10 * time.Second
+
+// Server serves the search API of the index at the index path of the options, or of the indexes
+// of the languages of a multilingual site. The indexes are registered by name in the registry of
+// bleve's http package, which is shared by the servers of the process.
+type Server struct {
+	logging
+	opts         Options
+	boosts       map[string]float64
+	ranking      ranking
+	bestBets     *bestBets
+	indexes      map[string]*servedIndex
+	indexNames   []string
+	defaultIndex string
+	reloader     *reloader
+	metrics      *metrics
+}
+
+// NewServer opens and registers the indexes, they are closed by Close
+func NewServer(opts Options) (*Server, error) {
+	s := &Server{logging: newLogging(opts), opts: opts, boosts: opts.Config.fieldBoosts(), ranking: opts.Config.ranking(), metrics: newMetrics()}
+	if len(opts.Config.CorsOrigins) == 0 {
+		s.log.Println("WARN: No CORS origins, the pages of other origins cannot query the server (see -corsOrigins)")
+	}
+
+	var err error
+	if s.bestBets, err = newBestBets(s.logging, opts.RulesFile); err != nil {
+		return nil, err
+	}
+	current, err := CurrentIndexPath(opts.IndexPath)
+	if err != nil {
+		return nil, err
+	}
+	s.indexes, s.indexNames, err = s.registerIndexes(current, opts.Combined)
+	if err != nil {
+		return nil, err
+	}
+
+	// a multilingual site without combined index has no default index for GET /api/search
+	if name := path.Base(opts.IndexPath); bleveHttp.IndexByName(name) != nil {
+		s.defaultIndex = name
+	}
+
+	// the indexes are rebuilt from the site when there is one, otherwise reopened
+	var indexer *Indexer
+	if opts.SitePath != "" {
+		indexer = NewIndexer(opts)
+	}
+	s.reloader = newReloader(s.logging, indexer, opts.IndexPath, current, s.indexes)
+	return s, nil
+}
+
+// ListenAndServe serves the search API on the address of the options until the context is done,
+// the requests in progress are then given 10 seconds to complete
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	server := &http.Server{Addr: s.opts.Addr, Handler: s.Handler()}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.log.Printf("Search server listening on %v", s.opts.Addr)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		return fmt.Errorf("requests interrupted: %v", err)
+	}
+	return nil
+}
+
+// Reload rebuilds the indexes from the site, or reopens them when there is no site path, and
+// swaps them into the served ones. Queries are answered by the previous indexes in the meantime.
+func (s *Server) Reload(ctx context.Context) error {
+	return s.reloader.reload(ctx)
+}
+
+// Watch reloads the indexes when the content, data or config of the site change, until the context is done
+func (s *Server) Watch(ctx context.Context) error {
+	if s.reloader.indexer == nil {
+		return fmt.Errorf("no site to watch")
+	}
+	return watchSite(ctx, s.reloader)
+}
+
+// WatchIndex reopens the indexes when another generation becomes current at the index path, like after
+// a rollback, until the context is done
+func (s *Server) WatchIndex(ctx context.Context) error {
+	return watchIndex(ctx, s.reloader)
+}
+
+// Close waits for the reload in progress, then unregisters and closes the indexes
+func (s *Server) Close() error {
+	s.reloader.close()
+	return unregisterIndexes(s.indexNames)
+}
+
+// registers the index at indexPath, or the index of each language of a multilingual site,
+// returns the indexes by language (empty for a single index) and the registered names.
+// Languages are registered by their code, the combined alias by the name of indexPath.
+func (s *Server) registerIndexes(indexPath string, combined bool) (map[string]*servedIndex, []string, error) {
+	indexName := path.Base(indexPath)
+	if isIndex(indexPath) {
+		index, err := s.registerIndex(indexPath, indexName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return map[string]*servedIndex{"": index}, []string{indexName}, nil
+	}
+
+	paths, err := findLanguageIndexes(indexPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no index found at %s", indexPath)
+	}
+	indexes := make(map[string]*servedIndex)
+	var indexNames []string
+	for lang, langPath := range paths {
+		index, err := s.registerIndex(langPath, lang)
+		if err != nil {
+			unregisterIndexes(indexNames)
+			return nil, nil, err
+		}
+		indexes[lang] = index
+		indexNames = append(indexNames, lang)
+	}
+	sort.Strings(indexNames)
+
+	if combined {
+		alias := &combinedIndex{IndexAlias: bleve.NewIndexAlias()}
+		for _, index := range indexes {
+			alias.Add(index)
+			alias.members = append(alias.members, index)
+		}
+		bleveHttp.RegisterIndexName(indexName, alias)
+		indexNames = append(indexNames, indexName)
+	}
+	return indexes, indexNames, nil
+}
+
+// unregisters and closes the indexes
+func unregisterIndexes(indexNames []string) error {
+	var closeErr error
+	for _, indexName := range indexNames {
+		if index := bleveHttp.UnregisterIndexByName(indexName); index != nil {
+			if err := index.Close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+	}
+	return closeErr
+}
+
+// servedIndex is the index registered for the search handler. Queries go through
+// an alias, so that a rebuilt index can be swapped in while the server is running.
+type servedIndex struct {
+	bleve.IndexAlias
+	mutex     sync.RWMutex
+	current   bleve.Index
+	warmUpErr error
+}
+
+// replaces the served index by the one passed, which answered a warm-up query. Queries see either the old or the new index.
+func (s *servedIndex) swap(index bleve.Index) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Swap waits for running queries to complete, so the old index can be closed afterwards
+	s.IndexAlias.Swap([]bleve.Index{index}, []bleve.Index{s.current})
+	s.current.Close()
+	s.current = index
+	s.warmUpErr = nil
+}
+
+// calls read with the served index, which is not closed by a swap until read returns. Searches
+// through the alias do not need it, but readers of the index itself do.
+func (s *servedIndex) read(read func(index bleve.Index) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return read(s.current)
+}
+
+// returns the path of the served index, the name of an index opened by bleve
+func (s *servedIndex) indexPath() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.current.Name()
+}
+
+// returns the error of the warm-up query of the served index
+func (s *servedIndex) warmUpError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.warmUpErr
+}
+
+// closes the alias and the index it points to
+func (s *servedIndex) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.IndexAlias.Close()
+	return s.current.Close()
+}
+
+// combinedIndex searches the indexes of all languages of a multilingual site
+type combinedIndex struct {
+	bleve.IndexAlias
+	members []bleve.Index
+}
+
+// returns the fields of all languages, an alias of several indexes does not list them
+func (c *combinedIndex) Fields() ([]string, error) {
+	found := make(map[string]bool)
+	var fields []string
+	for _, index := range c.members {
+		names, err := index.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !found[name] {
+				found[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// registers the index by its name so that handler can use it
+func (s *Server) registerIndex(indexPath string, indexName string) (*servedIndex, error) {
+	if s.verbose {
+		s.log.Printf("Registering index: %s", indexPath)
+	}
+	index, err := openReadOnlyIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	// an index that fails to warm up is served anyway, the server is not ready until it is replaced
+	served := &servedIndex{IndexAlias: bleve.NewIndexAlias(index), current: index, warmUpErr: warmUp(index)}
+	if served.warmUpErr != nil {
+		s.log.Printf("WARN: Index %s: %v", indexPath, served.warmUpErr)
+	}
+	bleveHttp.RegisterIndexName(indexName, served)
+	return served, nil
+}
+
+// opens the index for the search handler
+func openReadOnlyIndex(indexPath string) (bleve.Index, error) {
+	return bleve.OpenUsing(indexPath, map[string]interface{}{"read_only": true})
+}
+
+// Handler returns the handler of the search API with Cross Origin Resource Sharing (https://www.w3.org/TR/cors/)
+// for the origins, methods and headers of the settings. GET /api/search, /api/suggest and /api/related use the index of a single
+// language site or the combined index, unless another is selected by language. GET /metrics exposes the metrics of the
+// requests and of the indexes to prometheus, GET /healthz and /readyz the liveness and readiness of the server.
+func (s *Server) Handler() http.Handler {
+
+	// list of indexes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", bleveHttp.NewListIndexesHandler().ServeHTTP)
+	mux.Handle("/api/search", newQueryHandler(s, s.defaultIndex))
+	mux.Handle("/api/suggest", newSuggestHandler(s, s.defaultIndex))
+	mux.Handle("/api/related", newRelatedHandler(s, s.defaultIndex))
+
+	// actual search handlers
+	for _, indexName := range s.indexNames {
+		searchHandler := newSearchHandler(s, indexName)
+		mux.HandleFunc("/api/"+indexName+"/_search", searchHandler.ServeHTTP)
+	}
+	mux.Handle("/metrics", newMetricsHandler(s))
+	mux.Handle("/healthz", newHealthHandler(s))
+	mux.Handle("/readyz", newReadyHandler(s))
+	return cors.New(s.opts.Config.corsOptions()).Handler(s.instrument(mux))
+}
+
+// returns the CORS policy of the settings, without origins only the requests of the same origin are allowed
+func (c Config) corsOptions() cors.Options {
+	options := cors.Options{
+		AllowedOrigins:   c.CorsOrigins,
+		AllowedMethods:   c.CorsMethods,
+		AllowedHeaders:   c.CorsHeaders,
+		AllowCredentials: c.CorsCredentials,
+		MaxAge:           c.CorsMaxAge,
+	}
+	if len(c.CorsOrigins) == 0 {
+		// cors allows all origins by default
+		options.AllowOriginFunc = func(string) bool { return false }
+	}
+	return options
+}
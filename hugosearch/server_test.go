@@ -0,0 +1,151 @@
+package hugosearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// name of the test index in the registry, the name of its path
+const testIndexName = "search.bleve"
+
+type Response struct {
+	Status  string   `json:"status"`
+	Indexes []string `json:"indexes"`
+}
+
+// builds the index of the test site with the settings and serves it
+func newTestServer(t testing.TB, config Config) *Server {
+	opts := testOptions(t, config)
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestHttpServer(t *testing.T) {
+
+	// prepare index
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	// http recorder
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost/api", nil)
+
+	// http handler
+	handler := server.Handler()
+	handler.ServeHTTP(recorder, request)
+
+	expected := testIndexName
+
+	rawJSON := recorder.Body.String()
+	var response *Response
+	json.Unmarshal([]byte(rawJSON), &response)
+	actual := response.Indexes[0]
+
+	if actual != expected {
+		t.Errorf("Expected: %q, was: %q", expected, actual)
+	}
+}
+
+// checks that the server stops when the context is done
+func TestListenAndServe(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.Addr = "127.0.0.1:0"
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.ListenAndServe(ctx)
+	}()
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected server stopped, was: %v", err)
+	}
+}
+
+// checks that the index is reopened when there is no site
+func TestReload(t *testing.T) {
+	opts := testOptions(t, Config{})
+	buildTestIndex(t, opts)
+	server, err := NewServer(Options{IndexPath: opts.IndexPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	index := server.indexes[""]
+	previous := index.current
+	if err := server.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if index.current == previous {
+		t.Errorf("Expected index reopened")
+	}
+	queryIndex(t, index)
+}
+
+// checks that a missing index is reported
+func TestNewServerNoIndex(t *testing.T) {
+	if _, err := NewServer(Options{IndexPath: filepath.Join(t.TempDir(), "missing.bleve")}); err == nil {
+		t.Errorf("Expected error for missing index")
+	}
+}
+
+// sends a preflight request of the origin to the handler of the server
+func preflight(server *Server, origin string) http.Header {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("OPTIONS", "http://localhost/api/search", nil)
+	request.Header.Set("Origin", origin)
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	server.Handler().ServeHTTP(recorder, request)
+	return recorder.Header()
+}
+
+func TestHandlerCors(t *testing.T) {
+	server := newTestServer(t, Config{CorsOrigins: []string{"https://example.com"}, CorsCredentials: true, CorsMaxAge: 600})
+	defer server.Close()
+
+	header := preflight(server, "https://example.com")
+	if header.Get("Access-Control-Allow-Origin") != "https://example.com" ||
+		header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected origin, credentials and max age allowed, was: %v", header)
+	}
+	if header := preflight(server, "https://other.com"); header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected other origin not allowed, was: %v", header)
+	}
+}
+
+func TestHandlerCorsNoOrigins(t *testing.T) {
+	var logs bytes.Buffer
+	opts := testOptions(t, Config{})
+	opts.Logger = log.New(&logs, "", 0)
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if !strings.Contains(logs.String(), "WARN: No CORS origins") {
+		t.Errorf("Expected warning without origins, was: %s", logs.String())
+	}
+	if header := preflight(server, "https://example.com"); header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no origin allowed, was: %v", header)
+	}
+}
@@ -0,0 +1,248 @@
+package hugosearch
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve"
+	bleveIndex "github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+const (
+	defaultSuggestSize = 5
+	maxSuggestSize     = 20
+
+	// field whose terms complete the last word of the query
+	suggestTermsField = "content"
+
+	// number of hits searched for the titles: the sections of a page have its title, and words longer
+	// than the prefixes of the suggest field are checked on the titles of the hits
+	suggestTitlesWindow = 100
+
+	// number of terms of the dictionary read for the term suggestions, the first ones in alphabetical
+	// order, so that the short prefixes of a large site do not read the whole dictionary
+	maxSuggestDictTerms = 1000
+)
+
+// suggestHandler answers GET /api/suggest?q=...&size=...&lang=... with the titles
+// starting with the words typed so far and the terms completing the last word
+type suggestHandler struct {
+	server       *Server
+	defaultIndex string
+}
+
+func newSuggestHandler(server *Server, defaultIndex string) *suggestHandler {
+	return &suggestHandler{server: server, defaultIndex: defaultIndex}
+}
+
+// suggestResponse is the response of GET /api/suggest
+type suggestResponse struct {
+	Query  string            `json:"query"`
+	Titles []titleSuggestion `json:"titles"`
+	Terms  []termCount       `json:"terms"`
+}
+
+// titleSuggestion is a page whose title starts with the words of the query
+type titleSuggestion struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func (h *suggestHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		h.server.showError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := req.URL.Query()
+	index := h.server.requestedIndex(w, params, h.defaultIndex)
+	if index == nil {
+		return
+	}
+	words := strings.Fields(strings.ToLower(params.Get("q")))
+	if len(words) == 0 {
+		h.server.showError(w, "missing parameter q", http.StatusBadRequest)
+		return
+	}
+	size, err := intParam(params.Get("size"), defaultSuggestSize, 1, maxSuggestSize)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("invalid parameter size: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	titles, err := suggestTitles(index, words, size)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error executing query: %v", err), http.StatusInternalServerError)
+		return
+	}
+	terms, err := suggestTerms(index, words[len(words)-1], size)
+	if err != nil {
+		h.server.showError(w, fmt.Sprintf("error reading terms: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.server.writeJSON(w, &suggestResponse{Query: params.Get("q"), Titles: titles, Terms: terms})
+}
+
+// termCount is a term of the index with the number of documents it occurs in
+type termCount struct {
+	Term  string `json:"term"`
+	Count uint64 `json:"count"`
+}
+
+// returns the pages with a title word starting with each of the words
+func suggestTitles(index bleve.Index, words []string, size int) ([]titleSuggestion, error) {
+	var prefixes []query.Query
+	for _, word := range words {
+		// the suggest field holds the prefixes, so this is a prefix query that costs a term lookup
+		prefix := bleve.NewTermQuery(truncate(word, suggestMaxPrefix))
+		prefix.SetField(suggestField)
+		prefixes = append(prefixes, prefix)
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(prefixes...), suggestTitlesWindow, 0, false)
+	request.Fields = []string{"title"}
+	result, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	// the sections of a page have the title of the page
+	found := make(map[string]bool)
+	titles := []titleSuggestion{}
+	for _, hit := range result.Hits {
+		if len(titles) == size {
+			break
+		}
+		title, link := stringField(hit.Fields, "title"), pageLink(hit.ID)
+		if !found[link] && titleHasLongPrefixes(title, words) {
+			found[link] = true
+			titles = append(titles, titleSuggestion{Title: title, URL: link})
+		}
+	}
+	return titles, nil
+}
+
+// checks that the words longer than the prefixes of the suggest field start a word of the title,
+// their truncated prefix found by the query can start another word
+func titleHasLongPrefixes(title string, words []string) bool {
+	titleWords := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if utf8.RuneCountInString(word) <= suggestMaxPrefix {
+			continue
+		}
+		found := false
+		for _, titleWord := range titleWords {
+			if strings.HasPrefix(titleWord, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the first n characters of s
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// returns the terms of the index starting with prefix that occur in the most documents, among the
+// first maxSuggestDictTerms terms of the dictionary
+func suggestTerms(index bleve.Index, prefix string, size int) ([]termCount, error) {
+	served, ok := index.(*servedIndex)
+	if !ok {
+		return readSuggestTerms(index, prefix, size)
+	}
+	// the served index is read directly, a reload must not close it before the end
+	var terms []termCount
+	err := served.read(func(current bleve.Index) error {
+		var err error
+		terms, err = readSuggestTerms(current, prefix, size)
+		return err
+	})
+	return terms, err
+}
+
+// reads the suggested terms from the dictionary of the index
+func readSuggestTerms(index bleve.Index, prefix string, size int) ([]termCount, error) {
+	advanced, _, err := index.Advanced()
+	if err == bleve.ErrorAliasMulti {
+		// the combined index of a multilingual site has one dictionary per language
+		return []termCount{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	dict, err := reader.FieldDictPrefix(suggestTermsField, []byte(prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
+
+	terms := []termCount{}
+	for len(terms) < maxSuggestDictTerms {
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		terms = append(terms, termCount{entry.Term, entry.Count})
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return terms[i].Count > terms[j].Count
+	})
+
+	// the counts of the dictionary also include the documents deleted since its segment was written,
+	// the terms are counted again until the others cannot occur in more documents
+	counted := []termCount{}
+	for _, term := range terms {
+		if len(counted) >= size && counted[size-1].Count >= term.Count {
+			break
+		}
+		count, err := termDocCount(reader, term.Term)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			counted = append(counted, termCount{term.Term, count})
+			sort.SliceStable(counted, func(i, j int) bool {
+				return counted[i].Count > counted[j].Count
+			})
+		}
+	}
+	if len(counted) > size {
+		counted = counted[:size]
+	}
+	return counted, nil
+}
+
+// returns the number of documents having the term in the field of the term suggestions, read from
+// its postings without the deleted documents
+func termDocCount(reader bleveIndex.IndexReader, term string) (uint64, error) {
+	postings, err := reader.TermFieldReader([]byte(term), suggestTermsField, false, false, false)
+	if err != nil {
+		return 0, err
+	}
+	defer postings.Close()
+	return postings.Count(), nil
+}
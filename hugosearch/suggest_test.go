@@ -0,0 +1,143 @@
+package hugosearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// sends the GET request to the suggest API of the test index
+func getSuggest(t testing.TB, server *Server, q string) *suggestResponse {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://localhost/api/suggest?q="+q, nil)
+	newSuggestHandler(server, testIndexName).ServeHTTP(recorder, request)
+
+	var response suggestResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body.String())
+	}
+	return &response
+}
+
+func TestSuggestHandler(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	response := getSuggest(t, server, "tit+pa")
+	if len(response.Titles) != 3 {
+		t.Errorf("Expected 3 titles starting with 'tit pa', was: %+v", response.Titles)
+	}
+
+	response = getSuggest(t, server, "lor")
+	if len(response.Terms) != 1 || response.Terms[0].Term != "lorem" || response.Terms[0].Count != 3 {
+		t.Errorf("Expected term lorem in 3 documents, was: %+v", response.Terms)
+	}
+	if len(response.Titles) != 0 {
+		t.Errorf("Expected no title starting with 'lor', was: %+v", response.Titles)
+	}
+}
+
+// checks that the documents of deleted sections are not counted
+func TestSuggestTermsDeleted(t *testing.T) {
+	opts := testOptions(t, Config{SplitHeadings: true})
+	buildTestIndex(t, opts)
+	opts.Config = Config{}
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	response := getSuggest(t, server, "lor")
+	if len(response.Terms) != 1 || response.Terms[0].Count != 3 {
+		t.Errorf("Expected term lorem in 3 documents, was: %+v", response.Terms)
+	}
+}
+
+// checks that the served index is not closed by a swap while its dictionary is read
+func TestSuggestTermsSwapped(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+	served := server.indexes[""]
+	replacement, err := openReadOnlyIndex(server.reloader.current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swapped := make(chan struct{})
+	err = served.read(func(index bleve.Index) error {
+		go func() {
+			served.swap(replacement)
+			close(swapped)
+		}()
+		select {
+		case <-swapped:
+			t.Error("Expected the swap to wait for the read")
+		case <-time.After(100 * time.Millisecond):
+		}
+		terms, err := readSuggestTerms(index, "lor", 5)
+		if err == nil && len(terms) != 1 {
+			t.Errorf("Expected term lorem, was: %+v", terms)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-swapped
+	if response := getSuggest(t, server, "lor"); len(response.Terms) != 1 {
+		t.Errorf("Expected term lorem after the swap, was: %+v", response.Terms)
+	}
+}
+
+// checks that the words longer than the prefixes of the suggest field are not truncated
+func TestSuggestTitlesLongWords(t *testing.T) {
+	indexMapping, err := NewIndexer(testOptions(t, Config{})).newIndexMapping("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := bleve.New(filepath.Join(t.TempDir(), "search.bleve"), indexMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	index.Index("/a/", &PageEntry{Title: "Internationalizations overview"})
+	index.Index("/b/", &PageEntry{Title: "Internationalizationally"})
+
+	tests := map[string]int{"internationalization": 2, "internationalizations": 1, "internationalizations ov": 1, "internationalizationz": 0}
+	for q, expected := range tests {
+		titles, err := suggestTitles(index, strings.Fields(q), 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(titles) != expected {
+			t.Errorf("Expected %d titles starting with %q, was: %+v", expected, q, titles)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if actual := truncate("ééé", 2); actual != "éé" {
+		t.Errorf("Expected: éé, was: %s", actual)
+	}
+	if actual := truncate("ab", 3); actual != "ab" {
+		t.Errorf("Expected: ab, was: %s", actual)
+	}
+}
+
+func BenchmarkSuggestHandler(b *testing.B) {
+	server := newTestServer(b, Config{})
+	defer server.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getSuggest(b, server, "title+lo")
+	}
+}
@@ -0,0 +1,299 @@
+package hugosearch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/fsnotify/fsnotify"
+)
+
+// delay after the last change before the index is rebuilt (editors often write several files in a row)
+const watchDelay = 500 * time.Millisecond
+
+// watches the content, data and config of the hugo site and reloads the indexes when something changed
+func watchSite(ctx context.Context, r *reloader) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	paths, err := watchedPaths(r.indexer.opts.SitePath)
+	if err != nil {
+		return err
+	}
+	r.addWatches(watcher, paths)
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				r.addWatches(watcher, []string{event.Name})
+			}
+			if r.verbose {
+				r.log.Println("Changed:", event.Name)
+			}
+			pending = time.After(watchDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			r.log.Println("WARN: Watch failed:", err)
+		case <-pending:
+			pending = nil
+			if err := r.reload(ctx); err != nil {
+				r.log.Println("WARN: Reload failed:", err)
+			}
+
+			// config files replaced by an editor are not watched anymore
+			r.addWatches(watcher, paths)
+		}
+	}
+}
+
+// watches the generations file of the index path and reopens the indexes when another generation becomes
+// current, like after a rollback. The generations built by the reloader are already served.
+func watchIndex(ctx context.Context, r *reloader) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(r.served); err != nil {
+		return err
+	}
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Base(event.Name) == generationsFile && event.Op != fsnotify.Chmod {
+				pending = time.After(watchDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			r.log.Println("WARN: Watch failed:", err)
+		case <-pending:
+			pending = nil
+			if err := r.reloadChanged(); err != nil {
+				r.log.Println("WARN: Reload failed:", err)
+			}
+		}
+	}
+}
+
+// reloader swaps new indexes into the served ones, rebuilt by the indexer when there is one,
+// otherwise reopened from disk. The indexes are rebuilt in a new generation at the index path,
+// so that the served indexes are never modified.
+type reloader struct {
+	logging
+	mutex   sync.Mutex
+	indexer *Indexer
+	served  string
+	current string
+	indexes map[string]*servedIndex
+	closed  bool
+
+	// state of the reloads for the readiness of the server, which reads it while reloading
+	stateMutex sync.Mutex
+	swapping   bool
+	lastErr    error
+}
+
+// returns the reloader of the indexes served from indexPath, current is the path of their generation
+func newReloader(l logging, indexer *Indexer, indexPath string, current string, indexes map[string]*servedIndex) *reloader {
+	return &reloader{
+		logging: l,
+		indexer: indexer,
+		served:  indexPath,
+		current: current,
+		indexes: indexes,
+	}
+}
+
+// rebuilds or reopens the indexes and swaps them into the served ones
+func (r *reloader) reload(ctx context.Context) error {
+	return r.run(func() error {
+		if r.indexer == nil {
+			return r.reopenIndexes(true)
+		}
+		return r.rebuildIndexes(ctx)
+	})
+}
+
+// reopens the indexes when another generation became current at the index path, like after a rollback
+func (r *reloader) reloadChanged() error {
+	return r.run(func() error {
+		return r.reopenIndexes(false)
+	})
+}
+
+// runs the reload unless the reloader is closed, one reload at a time. The error is kept until the next reload.
+func (r *reloader) run(reload func() error) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	defer func() {
+		r.stateMutex.Lock()
+		defer r.stateMutex.Unlock()
+		r.lastErr = err
+	}()
+	return reload()
+}
+
+// waits for the reload in progress, the served indexes can be closed afterwards
+func (r *reloader) close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+}
+
+// rebuilds the indexes in a new generation at the index path and swaps them into the served indexes,
+// the previous generations beyond the options are removed once they are not served anymore
+func (r *reloader) rebuildIndexes(ctx context.Context) error {
+	r.log.Println("Rebuilding index:", r.served)
+	current, err := r.indexer.buildGeneration(ctx, r.served)
+	if err != nil {
+		return err
+	}
+	if err := r.swapIndexes(current); err != nil {
+		return err
+	}
+	return r.indexer.removeOldGenerations(r.served)
+}
+
+// reopens the indexes of the current generation at the index path, unless they are already served and always is false
+func (r *reloader) reopenIndexes(always bool) error {
+	current, err := CurrentIndexPath(r.served)
+	if err != nil {
+		return err
+	}
+	if current == r.current && !always {
+		return nil
+	}
+	r.log.Println("Reopening index:", current)
+	return r.swapIndexes(current)
+}
+
+// opens the indexes at indexPath, the index of a generation and the indexes of its languages, and swaps
+// them into the served indexes once they all answered a warm-up query. An error leaves the served indexes
+// as they were, so that the languages are never served from different generations.
+func (r *reloader) swapIndexes(indexPath string) error {
+	opened := make(map[string]bleve.Index)
+	closeOpened := func() {
+		for _, index := range opened {
+			index.Close()
+		}
+	}
+	for lang := range r.indexes {
+		path := indexPath
+		if lang != "" {
+			path = LanguageIndexPath(indexPath, lang)
+		}
+		// languages added or removed while running are served after a restart
+		if !isIndex(path) {
+			r.log.Println("WARN: Index not found:", path)
+			continue
+		}
+		index, err := openReadOnlyIndex(path)
+		if err != nil {
+			closeOpened()
+			return err
+		}
+		opened[lang] = index
+		if err := warmUp(index); err != nil {
+			closeOpened()
+			return fmt.Errorf("index %s: %v", path, err)
+		}
+	}
+
+	if len(opened) > 1 {
+		// the languages are swapped one after the other, queries of several languages can see old and new indexes
+		r.setSwapping(true)
+		defer r.setSwapping(false)
+	}
+	for lang, index := range opened {
+		r.indexes[lang].swap(index)
+	}
+	r.current = indexPath
+	return nil
+}
+
+func (r *reloader) setSwapping(swapping bool) {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	r.swapping = swapping
+}
+
+// returns whether the indexes are being swapped and the error of the last reload
+func (r *reloader) state() (bool, error) {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	return r.swapping, r.lastErr
+}
+
+// returns the config files and the content, data and config directories of the hugo site
+func watchedPaths(hugoPath string) ([]string, error) {
+	cfg, paths, err := loadSiteConfig(hugoPath)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(hugoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]string{"contentDir": "content", "dataDir": "data", "configDir": "config"}
+	for key, name := range dirs {
+		if cfg.IsSet(key) {
+			name = cfg.GetString(key)
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		paths = append(paths, name)
+	}
+	return paths, nil
+}
+
+// adds the paths to the watcher, directories are added with all their sub directories
+func (r *reloader) addWatches(watcher *fsnotify.Watcher, paths []string) {
+	for _, root := range paths {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			// paths that do not exist (e.g. no data directory) are not watched
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() || path == root {
+				if err := watcher.Add(path); err != nil {
+					r.log.Println("WARN: Cannot watch:", err)
+				}
+			}
+			return nil
+		})
+	}
+}
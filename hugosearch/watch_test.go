@@ -0,0 +1,74 @@
+package hugosearch
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// checks that a rebuilt index is swapped into the served one, the current generation at the index path
+func TestRebuildIndexes(t *testing.T) {
+	server := newTestServer(t, Config{})
+	defer server.Close()
+
+	index := server.indexes[""]
+	previous := index.current
+	if err := server.reloader.rebuildIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if index.current == previous {
+		t.Error("Expected the rebuilt index to be served")
+	}
+	expected, err := CurrentIndexPath(server.opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := index.current.Name(); actual != expected || server.reloader.current != expected {
+		t.Errorf("Expected: %q, was: %q", expected, actual)
+	}
+	queryIndex(t, index)
+}
+
+// checks that the generation rolled back is reopened by the server
+func TestReloadRolledBack(t *testing.T) {
+	opts := testOptions(t, Config{})
+	opts.Generations = 1
+	buildTestIndex(t, opts)
+	server, err := NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if err := server.reloader.rebuildIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Rollback(server.opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.reloader.reloadChanged(); err != nil {
+		t.Fatal(err)
+	}
+	index := server.indexes[""]
+	if actual := index.current.Name(); actual != expected {
+		t.Errorf("Expected: %q, was: %q", expected, actual)
+	}
+	queryIndex(t, index)
+}
+
+// checks that the content directory of the site is watched
+func TestWatchedPaths(t *testing.T) {
+	expected, _ := filepath.Abs(filepath.Join(testHugoPath, "content"))
+	paths, err := watchedPaths(testHugoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if path == expected {
+			return
+		}
+	}
+	t.Errorf("Expected %q in watched paths", expected)
+}
@@ -1,44 +1,267 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/tischda/hugo-search/hugosearch"
 )
 
 var version string
 
-var verbose = flag.Bool("verbose", false, "verbose output")
-
 func main() {
 	var (
-		bindAddr    = flag.String("addr", ":8080", "http listen address")
-		hugoPath    = flag.String("hugoPath", ".", "path of the hugo site")
-		indexPath   = flag.String("indexPath", "indexes/search.bleve", "path of the bleve index")
-		showVersion = flag.Bool("version", false, "print version and exit")
+		bindAddr        = flag.String("addr", ":8080", "http listen address")
+		batchSize       = flag.Int("batchSize", 0, "number of documents written to the index at once (default 500)")
+		combined        = flag.Bool("combined", false, "also serve all languages through a single index")
+		corsOrigins     = flag.String("corsOrigins", "", "comma-separated origins allowed to query the server, * for all (default origin of the baseURL)")
+		corsMethods     = flag.String("corsMethods", "", "comma-separated methods allowed in cross-origin requests")
+		corsHeaders     = flag.String("corsHeaders", "", "comma-separated headers allowed in cross-origin requests")
+		corsCredentials = flag.Bool("corsCredentials", false, "allow credentials in cross-origin requests")
+		corsMaxAge      = flag.Int("corsMaxAge", 0, "seconds that browsers cache the result of a preflight request")
+		buildDrafts     = flag.Bool("drafts", false, "include content marked as draft")
+		buildExpired    = flag.Bool("expired", false, "include expired content")
+		buildFuture     = flag.Bool("future", false, "include content with publishdate in the future")
+		format          = flag.String("format", hugosearch.FormatJSON, "format of the exported index (json or lunr)")
+		generations     = flag.Int("generations", 2, "number of previous indexes kept for rollback")
+		hugoPath        = flag.String("hugoPath", ".", "path of the hugo site")
+		indexPath       = flag.String("indexPath", "indexes/search.bleve", "directory of the index generations")
+		lang            = flag.String("lang", "", "language of the index used by query and stats")
+		mappingPath     = flag.String("mapping", "", "bleve index mapping file (JSON, YAML or TOML)")
+		outputDir       = flag.String("output", "", "directory of the exported index (default directory of the index path)")
+		rulesPath       = flag.String("rules", "", "file of the best bets rules (JSON, YAML or TOML)")
+		shardSize       = flag.Int("shardSize", 0, "maximum number of documents per file of the exported json index")
+		size            = flag.Int("size", 10, "number of hits printed by query")
+		verbose         = flag.Bool("verbose", false, "verbose output")
+		showVersion     = flag.Bool("version", false, "print version and exit")
+		watch           = flag.Bool("watch", false, "rebuild the index when the site changes")
+		workers         = flag.Int("workers", 0, "number of pages converted in parallel (default number of CPUs)")
 	)
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "\nUsage: %s [OPTIONS]\n\nOPTIONS:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nUsage: %s [OPTIONS] [COMMAND]\n\nCOMMANDS:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  index\t\t\tbuild the index only\n"+
+			"  serve\t\t\tserve an existing index without reading the site\n"+
+			"  query <query>\t\tsearch the index and print the hits\n"+
+			"  stats [field...]\tprint document count, fields and top terms (default field \"content\")\n"+
+			"  export\t\t\texport a static index for client-side search\n"+
+			"  rollback\t\tmake the previous generation of the index current\n"+
+			"  version\t\tprint version and exit\n\n"+
+			"Without command, the index is built and served. Options may follow the command and its\n"+
+			"arguments, a query starting with - follows --.\n\nOPTIONS:\n")
 		fmt.Fprintf(os.Stderr, "  -addr <string>\thttp listen address (default \"%s\")\n"+
+			"  -batchSize <int>\tnumber of documents written to the index at once (default 500)\n"+
+			"  -combined\t\talso serve all languages through a single index\n"+
+			"  -corsOrigins <string>\tcomma-separated origins allowed to query the server, * for all (default origin of the baseURL)\n"+
+			"  -corsMethods <string>\tcomma-separated methods allowed in cross-origin requests (default GET, POST, HEAD)\n"+
+			"  -corsHeaders <string>\tcomma-separated headers allowed in cross-origin requests\n"+
+			"  -corsCredentials\tallow credentials in cross-origin requests\n"+
+			"  -corsMaxAge <int>\tseconds that browsers cache the result of a preflight request\n"+
+			"  -drafts\t\tinclude content marked as draft\n"+
+			"  -expired\t\tinclude expired content\n"+
+			"  -format <string>\tformat of the exported index, json or lunr (default \"%s\")\n"+
+			"  -future\t\tinclude content with publishdate in the future\n"+
+			"  -generations <int>\tnumber of previous indexes kept for rollback (default %d)\n"+
 			"  -hugoPath <string>\tpath of the hugo site (default \"%s\")\n"+
-			"  -indexPath <string>\tpath of the bleve index (default \"%s\")\n"+
+			"  -indexPath <string>\tdirectory of the index generations (default \"%s\")\n"+
+			"  -lang <string>\t\tlanguage of the index used by query and stats\n"+
+			"  -mapping <string>\tbleve index mapping file (JSON, YAML or TOML)\n"+
+			"  -output <string>\tdirectory of the exported index (default directory of the index path)\n"+
+			"  -rules <string>\tfile of the best bets rules (JSON, YAML or TOML)\n"+
+			"  -shardSize <int>\tmaximum number of documents per file of the exported json index\n"+
+			"  -size <int>\t\tnumber of hits printed by query (default %d)\n"+
 			"  -verbose\t\tverbose output\n"+
-			"  -version\t\tprint version and exit\n", *bindAddr, *hugoPath, *indexPath)
+			"  -version\t\tprint version and exit\n"+
+			"  -watch\t\trebuild the index when the site changes\n"+
+			"  -workers <int>\tnumber of pages converted in parallel (default number of CPUs)\n", *bindAddr, *format, *generations, *hugoPath, *indexPath, *size)
 	}
 	flag.Parse()
-	if !flag.Parsed() || flag.NArg() > 0 {
+	if !flag.Parsed() {
 		flag.Usage()
 		os.Exit(1)
 	}
-	if flag.Arg(0) == "version" || *showVersion {
+
+	// options are also accepted after the command and its arguments
+	command := flag.Arg(0)
+	var args []string
+	if command != "" {
+		var err error
+		args, err = parseArgs(flag.CommandLine, flag.Args()[1:])
+		exitOnError(err)
+	}
+
+	if command == "version" || *showVersion {
 		fmt.Println("hugo-search", version)
 		return
 	}
 	log.SetFlags(0)
 
-	buildIndexFromSite(*hugoPath, *indexPath)
-	startSearchServer(*bindAddr, *indexPath)
+	// the site config provides the options not set on the command line, the commands
+	// that only read the index do not need the site
+	var config hugosearch.Config
+	if readsSiteConfig(command, setFlags()) {
+		var err error
+		config, err = hugosearch.ReadConfig(*hugoPath)
+		exitOnError(err)
+		applySearchConfig(config, *hugoPath, bindAddr, indexPath, rulesPath)
+	}
+	exitOnError(applyCorsFlags(&config, setFlags(), *corsOrigins, *corsMethods, *corsHeaders, *corsCredentials, *corsMaxAge))
+
+	opts := hugosearch.Options{
+		SitePath:     *hugoPath,
+		IndexPath:    *indexPath,
+		MappingFile:  *mappingPath,
+		RulesFile:    *rulesPath,
+		Addr:         *bindAddr,
+		Combined:     *combined,
+		BuildDrafts:  *buildDrafts,
+		BuildFuture:  *buildFuture,
+		BuildExpired: *buildExpired,
+		Generations:  *generations,
+		BatchSize:    *batchSize,
+		Workers:      *workers,
+		Config:       config,
+		Verbose:      *verbose,
+	}
+
+	switch command {
+	case "":
+		buildIndex(opts)
+		exitOnError(serve(opts, *watch))
+	case "index":
+		buildIndex(opts)
+	case "serve":
+		// the index is reopened instead of rebuilt from the site
+		opts.SitePath = ""
+		exitOnError(serve(opts, false))
+	case "query":
+		if len(args) == 0 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		queryCommand(os.Stdout, commandIndexPath(*indexPath, *lang), strings.Join(args, " "), *size)
+	case "stats":
+		if len(args) == 0 {
+			args = []string{"content"}
+		}
+		statsCommand(os.Stdout, commandIndexPath(*indexPath, *lang), args)
+	case "rollback":
+		rollbackIndex(*indexPath)
+	case "export":
+		exportIndex(opts, hugosearch.ExportOptions{Format: *format, Dir: *outputDir, ShardSize: *shardSize})
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// returns the path of the index read by the commands: the index of the language in the current
+// generation, multilingual sites have one index per language
+func commandIndexPath(indexPath string, lang string) string {
+	path, err := hugosearch.CurrentIndexPath(indexPath)
+	exitOnError(err)
+	if lang != "" {
+		path = hugosearch.LanguageIndexPath(path, lang)
+	}
+	return path
+}
+
+// builds the index of the site
+func buildIndex(opts hugosearch.Options) {
+	exitOnError(hugosearch.NewIndexer(opts).Build(context.Background()))
+}
+
+// exports the index of the site for client-side search and prints the written files
+func exportIndex(opts hugosearch.Options, eo hugosearch.ExportOptions) {
+	files, err := hugosearch.NewIndexer(opts).Export(context.Background(), eo)
+	exitOnError(err)
+	for _, file := range files {
+		log.Println("Exported:", file)
+	}
+}
+
+// makes the previous generation of the index and of the indexes of its languages current and prints it
+func rollbackIndex(indexPath string) {
+	path, err := hugosearch.Rollback(indexPath)
+	exitOnError(err)
+	log.Println("Rolled back to:", path)
+}
+
+// serves the index until SIGINT or SIGTERM, SIGHUP reloads it. With watch,
+// the index is also rebuilt when the site changes. The indexes are closed before
+// the error of the server is returned.
+func serve(opts hugosearch.Options, watch bool) error {
+	server, err := hugosearch.NewServer(opts)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if watch {
+		go func() {
+			if err := server.Watch(ctx); err != nil {
+				log.Println("WARN: Watch failed:", err)
+			}
+		}()
+	}
+	go func() {
+		if err := server.WatchIndex(ctx); err != nil {
+			log.Println("WARN: Watch failed:", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	reload := func() {
+		if err := server.Reload(ctx); err != nil {
+			log.Println("WARN: Reload failed:", err)
+		}
+	}
+	go handleSignals(signals, reload, cancel)
+
+	return server.ListenAndServe(ctx)
+}
+
+// handles the signals until one of them stops the server, SIGHUP reloads the index
+func handleSignals(signals <-chan os.Signal, reload func(), stop func()) {
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			go reload()
+			continue
+		}
+		log.Println("Stopping search server:", sig)
+		stop()
+		return
+	}
+}
+
+// parses the options among the arguments of the command, returns the other arguments.
+// The arguments after -- are not parsed.
+func parseArgs(flags *flag.FlagSet, arguments []string) ([]string, error) {
+	var args []string
+	for len(arguments) > 0 {
+		if err := flags.Parse(arguments); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if parsed := len(arguments) - len(rest); parsed > 0 && arguments[parsed-1] == "--" {
+			return append(args, rest...), nil
+		}
+		if len(rest) == 0 {
+			break
+		}
+		args = append(args, rest[0])
+		arguments = rest[1:]
+	}
+	return args, nil
 }
 
 func exitOnError(e error) {
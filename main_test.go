@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"syscall"
+	"testing"
+
+	bleveHttp "github.com/blevesearch/bleve/http"
+	"github.com/tischda/hugo-search/hugosearch"
+)
+
+// checks that SIGHUP reloads the index and that SIGTERM stops the server
+func TestHandleSignals(t *testing.T) {
+	signals := make(chan os.Signal)
+	reloaded, stopped := make(chan bool), make(chan bool)
+	go handleSignals(signals, func() { reloaded <- true }, func() { stopped <- true })
+
+	signals <- syscall.SIGHUP
+	<-reloaded
+	signals <- syscall.SIGTERM
+	<-stopped
+}
+
+// checks that the options after the arguments of a command are parsed, except after --
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		arguments []string
+		args      []string
+		size      int
+	}{
+		{[]string{"lorem", "ipsum"}, []string{"lorem", "ipsum"}, 10},
+		{[]string{"lorem", "-size", "3", "ipsum"}, []string{"lorem", "ipsum"}, 3},
+		{[]string{"-size", "3", "--", "lorem", "-ipsum", "-size"}, []string{"lorem", "-ipsum", "-size"}, 3},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		size := flags.Int("size", 10, "")
+		args, err := parseArgs(flags, test.arguments)
+		if err != nil || !reflect.DeepEqual(args, test.args) || *size != test.size {
+			t.Errorf("%v: expected %v with size %d, was: %v with size %d (%v)", test.arguments, test.args, test.size, args, *size, err)
+		}
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	if _, err := parseArgs(flags, []string{"lorem", "-unknown"}); err == nil {
+		t.Error("Expected error for unknown option after the arguments")
+	}
+}
+
+// checks that the indexes are closed when the server cannot listen
+func TestServeListenError(t *testing.T) {
+	indexPath := buildTestIndex(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := serve(hugosearch.Options{IndexPath: indexPath, Addr: listener.Addr().String()}, false); err == nil {
+		t.Fatal("Expected error for address in use")
+	}
+	if bleveHttp.IndexByName(path.Base(indexPath)) != nil {
+		t.Error("Expected index to be closed")
+	}
+}
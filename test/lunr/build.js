@@ -0,0 +1,39 @@
+"use strict";
+
+// build.js writes index.json, the indexes that lunr.js builds from the pages of pages.json, which
+// the lunr indexes of hugo-search must match (see TestLunrGolden). English pages go through the
+// default pipeline of lunr(), the pages of other languages are only trimmed like hugo-search does.
+//
+//     npm install lunr@2.3.9
+//     node test/lunr/build.js
+var fs = require("fs");
+var path = require("path");
+var lunr = require("lunr");
+
+function trimmer(token) {
+    return token.update(function(s) {
+        return s.replace(/^[^\p{L}\p{N}]+/u, "").replace(/[^\p{L}\p{N}]+$/u, "");
+    });
+}
+lunr.Pipeline.registerFunction(trimmer, "hugoSearchTrimmer");
+
+var pages = JSON.parse(fs.readFileSync(path.join(__dirname, "pages.json"), "utf8"));
+var indexes = {};
+Object.keys(pages.languages).forEach(function(lang) {
+    var builder = new lunr.Builder();
+    if (lang === "en") {
+        builder.pipeline.add(lunr.trimmer, lunr.stopWordFilter, lunr.stemmer);
+        builder.searchPipeline.add(lunr.stemmer);
+    } else {
+        builder.pipeline.add(trimmer);
+    }
+    builder.ref("ref");
+    pages.fields.forEach(function(field) {
+        builder.field(field, {boost: pages.boosts[field] || 1});
+    });
+    pages.languages[lang].forEach(function(page) {
+        builder.add(page);
+    });
+    indexes[lang] = builder.build().toJSON();
+});
+fs.writeFileSync(path.join(__dirname, "index.json"), JSON.stringify(indexes, null, 1) + "\n");